@@ -0,0 +1,240 @@
+package fproto_wrap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Number of context lines around each change of an unified diff
+const diffContextLines = 3
+
+// Maximum number of edits searched for the middle of a path. Lines that need more edits are shown as fully replaced,
+// which keeps the diff of heavily rewritten files fast.
+const diffMaxEdits = 1000
+
+type diffOp struct {
+	kind byte   // ' ', '-' or '+'
+	line string // with its line terminator, if any
+	aidx int    // position on the "from" lines
+	bidx int    // position on the "to" lines
+}
+
+// Returns an unified diff between two contents, or a blank string if they are equal.
+func UnifiedDiff(fromName string, toName string, from []byte, to []byte) string {
+	if bytes.Equal(from, to) {
+		return ""
+	}
+
+	ops := diffLines(splitLines(from), splitLines(to))
+
+	// mark the operations that are included in a hunk
+	include := make([]bool, len(ops))
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		for j := i - diffContextLines; j <= i+diffContextLines; j++ {
+			if j >= 0 && j < len(ops) {
+				include[j] = true
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "--- %s\n", fromName)
+	fmt.Fprintf(&b, "+++ %s\n", toName)
+
+	for i := 0; i < len(ops); {
+		if !include[i] {
+			i++
+			continue
+		}
+
+		// contiguous included operations form a hunk
+		end := i
+		for end < len(ops) && include[end] {
+			end++
+		}
+
+		var acount, bcount int
+		for _, op := range ops[i:end] {
+			if op.kind != '+' {
+				acount++
+			}
+			if op.kind != '-' {
+				bcount++
+			}
+		}
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(ops[i].aidx, acount), hunkRange(ops[i].bidx, bcount))
+		for _, op := range ops[i:end] {
+			b.WriteByte(op.kind)
+			b.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		i = end
+	}
+
+	return b.String()
+}
+
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// Splits the content into lines, keeping the line terminators, so a missing newline at the end is a difference
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Computes the line operations to transform a into b, using the linear space variant of the Myers diff algorithm,
+// which splits the problem on the middle of an optimal path. Parts that need more than diffMaxEdits edits are
+// replaced as a whole, so the result is not the shortest one for them.
+func diffLines(a []string, b []string) []diffOp {
+	var ops []diffOp
+	diffRange(a, b, 0, 0, &ops)
+	return ops
+}
+
+// Appends the operations to transform a into b. aoffset and boffset are the positions of a and b on the full lines.
+func diffRange(a []string, b []string, aoffset int, boffset int, ops *[]diffOp) {
+	// common prefix
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		*ops = append(*ops, diffOp{kind: ' ', line: a[prefix], aidx: aoffset + prefix, bidx: boffset + prefix})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	aoffset, boffset = aoffset+prefix, boffset+prefix
+
+	// common suffix, added after the changes
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-suffix-1] == b[len(b)-suffix-1] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for i, line := range b {
+			*ops = append(*ops, diffOp{kind: '+', line: line, aidx: aoffset, bidx: boffset + i})
+		}
+	case len(b) == 0:
+		for i, line := range a {
+			*ops = append(*ops, diffOp{kind: '-', line: line, aidx: aoffset + i, bidx: boffset})
+		}
+	default:
+		x, y := diffMiddle(a, b)
+		diffRange(a[:x], b[:y], aoffset, boffset, ops)
+		diffRange(a[x:], b[y:], aoffset+x, boffset+y, ops)
+	}
+
+	for i, line := range common {
+		*ops = append(*ops, diffOp{kind: ' ', line: line, aidx: aoffset + len(a) + i, bidx: boffset + len(b) + i})
+	}
+}
+
+// Returns a point in the middle of an optimal path from the start to the end of a and b, searching from both ends
+// at the same time, keeping only the furthest point reached on each diagonal. a and b must not be empty, and must
+// not start or end with the same line.
+func diffMiddle(a []string, b []string) (int, int) {
+	n, m := len(a), len(b)
+	maxd := (n + m + 1) / 2
+	offset := maxd
+
+	// vf[k] and vr[k] are the furthest x reached on diagonal k from the start and from the end, or -1
+	vf := make([]int, 2*maxd+2)
+	vr := make([]int, 2*maxd+2)
+	for i := range vf {
+		vf[i] = -1
+		vr[i] = -1
+	}
+	vf[offset+1] = 0
+	vr[offset+1] = 0
+
+	delta := n - m
+	// with an odd delta, the paths meet on a forward step, otherwise on a reverse one
+	front := delta%2 != 0
+
+	// diagonals that left the edit graph are skipped
+	var kfstart, kfend, krstart, krend int
+
+	for d := 0; d < maxd && d < diffMaxEdits; d++ {
+		for k := -d + kfstart; k <= d-kfend; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+
+			if x > n {
+				kfend += 2
+			} else if y > m {
+				kfstart += 2
+			} else if front {
+				kr := offset + delta - k
+				if kr >= 0 && kr < len(vr) && vr[kr] != -1 && x >= n-vr[kr] {
+					return x, y
+				}
+			}
+		}
+
+		for k := -d + krstart; k <= d-krend; k += 2 {
+			var x int
+			if k == -d || (k != d && vr[offset+k-1] < vr[offset+k+1]) {
+				x = vr[offset+k+1]
+			} else {
+				x = vr[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			vr[offset+k] = x
+
+			if x > n {
+				krend += 2
+			} else if y > m {
+				krstart += 2
+			} else if !front {
+				kf := offset + delta - k
+				if kf >= 0 && kf < len(vf) && vf[kf] != -1 {
+					fx := vf[kf]
+					fy := offset + fx - kf
+					if fx >= n-x {
+						return fx, fy
+					}
+				}
+			}
+		}
+	}
+
+	// no common lines, or too many edits: delete all of a, then insert all of b
+	return n, 0
+}
@@ -0,0 +1,137 @@
+package fproto_wrap
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		expected string
+	}{
+		{
+			name: "equal",
+			from: "a\nb\n",
+			to:   "a\nb\n",
+		},
+		{
+			name:     "insert",
+			from:     "a\nb\nc\n",
+			to:       "a\nb\nx\nc\n",
+			expected: "--- from\n+++ to\n@@ -1,3 +1,4 @@\n a\n b\n+x\n c\n",
+		},
+		{
+			name:     "delete",
+			from:     "a\nb\nc\n",
+			to:       "a\nc\n",
+			expected: "--- from\n+++ to\n@@ -1,3 +1,2 @@\n a\n-b\n c\n",
+		},
+		{
+			name:     "replace",
+			from:     "a\nb\nc\n",
+			to:       "a\nx\nc\n",
+			expected: "--- from\n+++ to\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			name:     "empty from",
+			from:     "",
+			to:       "a\nb\n",
+			expected: "--- from\n+++ to\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:     "empty to",
+			from:     "a\n",
+			to:       "",
+			expected: "--- from\n+++ to\n@@ -1 +0,0 @@\n-a\n",
+		},
+		{
+			name:     "missing trailing newline",
+			from:     "a\nb\n",
+			to:       "a\nb",
+			expected: "--- from\n+++ to\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			name:     "separate hunks",
+			from:     "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			to:       "x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n",
+			expected: "--- from\n+++ to\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+y\n",
+		},
+	}
+
+	for _, test := range tests {
+		diff := UnifiedDiff("from", "to", []byte(test.from), []byte(test.to))
+		if diff != test.expected {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", test.name, test.expected, diff)
+		}
+	}
+}
+
+// Length of the shortest edit script, by dynamic programming
+func testEditDistance(a []string, b []string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				cur[j] = prev[j-1]
+			} else if prev[j] < cur[j-1] {
+				cur[j] = prev[j] + 1
+			} else {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestDiffLinesShortest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		ret := make([]string, rnd.Intn(12))
+		for i := range ret {
+			ret[i] = string(rune('a' + rnd.Intn(3)))
+		}
+		return ret
+	}
+
+	for i := 0; i < 2000; i++ {
+		a, b := randomLines(), randomLines()
+		ops := diffLines(a, b)
+
+		var froma, fromb []string
+		edits := 0
+		for j, op := range ops {
+			if op.kind != '+' {
+				if op.aidx != len(froma) {
+					t.Fatalf("%v -> %v: operation %d has position %d on a, expected %d", a, b, j, op.aidx, len(froma))
+				}
+				froma = append(froma, op.line)
+			}
+			if op.kind != '-' {
+				if op.bidx != len(fromb) {
+					t.Fatalf("%v -> %v: operation %d has position %d on b, expected %d", a, b, j, op.bidx, len(fromb))
+				}
+				fromb = append(fromb, op.line)
+			}
+			if op.kind != ' ' {
+				edits++
+			}
+		}
+
+		if strings.Join(froma, ",") != strings.Join(a, ",") || strings.Join(fromb, ",") != strings.Join(b, ",") {
+			t.Fatalf("%v -> %v: the operations don't rebuild the lines: %v", a, b, ops)
+		}
+		if expected := testEditDistance(a, b); edits != expected {
+			t.Fatalf("%v -> %v: %d edits, the shortest has %d", a, b, edits, expected)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/RangelReale/fproto-wrap/golden"
+)
+
+// Array flags type
+type arrayFlags []string
+
+func (i *arrayFlags) String() string {
+	return "array flags"
+}
+
+func (i *arrayFlags) Set(value string) error {
+	*i = append(*i, value)
+	return nil
+}
+
+// command line flags
+var (
+	incPaths    = arrayFlags{}
+	fixturePath = flag.String("fixture_path", filepath.Join("testdata", "proto"), "Fixture proto files root path")
+	goldenPath  = flag.String("golden_path", filepath.Join("testdata", "golden"), "Golden files root path")
+	update      = flag.Bool("update", false, "Regenerate the golden files instead of comparing them")
+)
+
+// Usage (from the "golden" directory):
+// fproto-wrap-golden
+// fproto-wrap-golden -update
+func main() {
+	// parse command line flags
+	flag.Var(&incPaths, "inc_path", "Include paths (can be set multiple times)")
+	flag.Parse()
+
+	suite := fproto_wrap_golden.NewSuite(*fixturePath, *goldenPath)
+	suite.IncludeDirs = append(suite.IncludeDirs, incPaths...)
+
+	mismatches, err := suite.Run(*update)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *update {
+		log.Printf("Golden files updated in %s", *goldenPath)
+		return
+	}
+
+	err = fproto_wrap_golden.Report(os.Stdout, mismatches)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,296 @@
+package fproto_wrap_golden
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/RangelReale/fdep"
+	"github.com/RangelReale/fproto-wrap"
+	"github.com/RangelReale/fproto-wrap/gowrap"
+	"github.com/RangelReale/fproto-wrap/phpwrap"
+)
+
+// Extension added to the golden files, so Go tooling never picks them up
+const GOLDEN_EXT = ".golden"
+
+// Function that runs a wrapper generator against the parsed fixtures, returning the
+// generated contents keyed by filename
+type GenerateFunc func(dep *fdep.Dep) (map[string][]byte, error)
+
+// A golden file suite. Each generator output is compared against the files
+// in GoldenPath/<generator name>.
+type Suite struct {
+	// Root path of the fixture .proto files. All files are parsed as owned.
+	FixturePath string
+
+	// Include paths for imports outside the fixtures
+	IncludeDirs []string
+
+	// Root path of the golden files
+	GoldenPath string
+
+	// Generators to run, by name
+	Generators map[string]GenerateFunc
+}
+
+// Creates a new suite with the default gowrap and phpwrap generators, and the gowrap generator with the optional
+// features enabled
+func NewSuite(fixturePath string, goldenPath string) *Suite {
+	return &Suite{
+		FixturePath: fixturePath,
+		GoldenPath:  goldenPath,
+		Generators: map[string]GenerateFunc{
+			"gowrap":          GenerateGo,
+			"gowrap_features": GenerateGoFeatures(fixturePath),
+			"phpwrap":         GeneratePHP,
+		},
+	}
+}
+
+// A difference between a generated and a golden file
+type Mismatch struct {
+	Generator string
+	Filename  string
+	// "changed", "missing" (no golden file) or "extra" (golden file not generated)
+	Kind   string
+	Detail string
+}
+
+func (m *Mismatch) String() string {
+	if m.Detail != "" {
+		return fmt.Sprintf("[%s] %s: %s\n%s", m.Generator, m.Filename, m.Kind, m.Detail)
+	}
+	return fmt.Sprintf("[%s] %s: %s", m.Generator, m.Filename, m.Kind)
+}
+
+// Parses the fixture files
+func (s *Suite) ParseFixtures() (*fdep.Dep, error) {
+	parsedep := fdep.NewDep()
+	parsedep.IncludeDirs = append(parsedep.IncludeDirs, s.IncludeDirs...)
+
+	err := parsedep.AddPath(s.FixturePath, fdep.DepType_Own)
+	if err != nil {
+		return nil, err
+	}
+
+	err = parsedep.CheckDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	return parsedep, nil
+}
+
+// Runs all generators and compares the outputs with the golden files.
+// If update is true, the golden files are rewritten instead, and no mismatches are returned.
+func (s *Suite) Run(update bool) ([]*Mismatch, error) {
+	dep, err := s.ParseFixtures()
+	if err != nil {
+		return nil, err
+	}
+
+	// run generators in ascending order
+	var names []string
+	for name := range s.Generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ret []*Mismatch
+	for _, name := range names {
+		files, err := s.Generators[name](dep)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %v", name, err)
+		}
+
+		goldenPath := filepath.Join(s.GoldenPath, name)
+		if update {
+			err = UpdateGolden(goldenPath, files)
+			if err != nil {
+				return nil, fmt.Errorf("[%s] %v", name, err)
+			}
+			continue
+		}
+
+		mm, err := CompareGolden(goldenPath, files)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %v", name, err)
+		}
+		for _, m := range mm {
+			m.Generator = name
+		}
+		ret = append(ret, mm...)
+	}
+
+	return ret, nil
+}
+
+// Compares the generated files with the golden files in goldenPath.
+func CompareGolden(goldenPath string, files map[string][]byte) ([]*Mismatch, error) {
+	existing, err := listGolden(goldenPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*Mismatch
+	for _, fn := range sortedFilenames(files) {
+		golden, err := ioutil.ReadFile(filepath.Join(goldenPath, filepath.FromSlash(fn)+GOLDEN_EXT))
+		if os.IsNotExist(err) {
+			ret = append(ret, &Mismatch{Filename: fn, Kind: "missing"})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		delete(existing, fn)
+
+		if !bytes.Equal(golden, files[fn]) {
+			ret = append(ret, &Mismatch{Filename: fn, Kind: "changed", Detail: fproto_wrap.UnifiedDiff(fn+GOLDEN_EXT, fn, golden, files[fn])})
+		}
+	}
+
+	for _, fn := range sortedFilenames(existing) {
+		ret = append(ret, &Mismatch{Filename: fn, Kind: "extra"})
+	}
+
+	return ret, nil
+}
+
+// Rewrites the golden files in goldenPath, removing the ones that were not generated.
+func UpdateGolden(goldenPath string, files map[string][]byte) error {
+	existing, err := listGolden(goldenPath)
+	if err != nil {
+		return err
+	}
+
+	for fn, content := range files {
+		p := filepath.Join(goldenPath, filepath.FromSlash(fn)+GOLDEN_EXT)
+
+		err := os.MkdirAll(filepath.Dir(p), os.ModePerm)
+		if err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(p, content, 0644)
+		if err != nil {
+			return err
+		}
+
+		delete(existing, fn)
+	}
+
+	for fn := range existing {
+		err := os.Remove(filepath.Join(goldenPath, filepath.FromSlash(fn)+GOLDEN_EXT))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Lists the golden files in the path, keyed by the generated filename
+func listGolden(goldenPath string) (map[string][]byte, error) {
+	ret := make(map[string][]byte)
+
+	if _, err := os.Stat(goldenPath); os.IsNotExist(err) {
+		return ret, nil
+	}
+
+	err := filepath.Walk(goldenPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(p, GOLDEN_EXT) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(goldenPath, p)
+		if err != nil {
+			return err
+		}
+		ret[strings.TrimSuffix(filepath.ToSlash(rel), GOLDEN_EXT)] = nil
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func sortedFilenames(files map[string][]byte) []string {
+	var ret []string
+	for fn := range files {
+		ret = append(ret, fn)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Writes a report of the mismatches, returning an error if there were any
+func Report(w io.Writer, mismatches []*Mismatch) error {
+	for _, m := range mismatches {
+		fmt.Fprintln(w, m.String())
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%d golden file(s) differ, run with -update to regenerate them", len(mismatches))
+	}
+	return nil
+}
+
+//
+// Generators
+//
+
+// Generates the fixtures using the Go wrapper, with gRPC services
+func GenerateGo(dep *fdep.Dep) (map[string][]byte, error) {
+	w := fproto_gowrap.NewWrapper(dep)
+	w.ServiceGen = fproto_gowrap.NewServiceGen_gRPC()
+
+	output := fproto_gowrap.NewFileOutput_Memory()
+	err := w.Generate(output)
+	if err != nil {
+		return nil, err
+	}
+	return output.Files(), nil
+}
+
+// Returns a generator of the fixtures using the Go wrapper with the optional features enabled: wrapped enums, unknown
+// fields, and source maps and position comments, with the positions scanned from the fixture files
+func GenerateGoFeatures(fixturePath string) GenerateFunc {
+	return func(dep *fdep.Dep) (map[string][]byte, error) {
+		w := fproto_gowrap.NewWrapper(dep)
+		w.ServiceGen = fproto_gowrap.NewServiceGen_gRPC()
+		w.EnumWrap = true
+		w.KeepUnknownFields = true
+		w.SourceMap = true
+		w.PositionComments = true
+		w.Positions = fproto_gowrap.NewPositionSource_Scan(fixturePath)
+
+		output := fproto_gowrap.NewFileOutput_Memory()
+		err := w.Generate(output)
+		if err != nil {
+			return nil, err
+		}
+		return output.Files(), nil
+	}
+}
+
+// Generates the fixtures using the PHP wrapper, with gRPC services
+func GeneratePHP(dep *fdep.Dep) (map[string][]byte, error) {
+	w := fproto_phpwrap.NewWrapper(dep)
+	w.ServiceGen = fproto_phpwrap.NewServiceGen_gRPC()
+
+	output := fproto_phpwrap.NewFileOutput_Memory()
+	err := w.Generate(output)
+	if err != nil {
+		return nil, err
+	}
+	return output.Files(), nil
+}
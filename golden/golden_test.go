@@ -0,0 +1,31 @@
+package fproto_wrap_golden
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Regenerate the golden files instead of comparing them")
+
+// Compares the generator outputs with the golden files. Run "go test ./golden -update" to regenerate them.
+func TestGolden(t *testing.T) {
+	suite := NewSuite(filepath.Join("testdata", "proto"), filepath.Join("testdata", "golden"))
+
+	mismatches, err := suite.Run(*update)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		t.Logf("Golden files updated in %s", suite.GoldenPath)
+		return
+	}
+
+	for _, m := range mismatches {
+		t.Error(m.String())
+	}
+	if len(mismatches) > 0 {
+		t.Logf("%d golden file(s) differ, run \"go test ./golden -update\" to regenerate them", len(mismatches))
+	}
+}
@@ -0,0 +1,353 @@
+package fproto_gowrap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/RangelReale/fdep"
+	"github.com/RangelReale/fproto"
+	"github.com/RangelReale/fproto-wrap"
+)
+
+// Version of the generated code. Must be changed whenever a change on the generator changes its output,
+// so the files on incremental generation caches are generated again.
+const GENERATOR_VERSION = "7"
+
+// Default name of the cache file, in the output path
+const CACHE_FILENAME = ".fproto-gowrap.cache"
+
+// Optional FileOutput interface, for outputs that can keep a file from a previous run without generating it again.
+// Required for the incremental generation cache.
+type FileOutput_Keep interface {
+	// Keeps a file generated on a previous run. Returns false if the file is not available anymore.
+	Keep(filename string) (bool, error)
+}
+
+// Optional interface for the plugins and package sources on the incremental generation cache hash.
+// They are hashed by their fields, and functions only by their type, so the ones whose output depends on functions
+// or on external state must return a key that changes with their output.
+type CacheKey interface {
+	CacheKey() string
+}
+
+// Incremental generation cache.
+// Keeps a hash of the inputs of each generated proto file: the file itself and all its transitive imports,
+// the element positions written to the output, the wrapper settings and plugins, and GENERATOR_VERSION.
+// On the next run, files with the same hash are not generated again.
+type Cache struct {
+	Filename string
+
+	previous map[string]*CacheEntry
+	current  map[string]*CacheEntry
+}
+
+// Cached data of a proto file
+type CacheEntry struct {
+	// Hash of the inputs
+	Hash string `json:"hash"`
+
+	// Generated filenames
+	Outputs []string `json:"outputs"`
+}
+
+type cacheFile struct {
+	Version string                 `json:"version"`
+	Files   map[string]*CacheEntry `json:"files"`
+}
+
+func NewCache(filename string) *Cache {
+	return &Cache{
+		Filename: filename,
+		previous: make(map[string]*CacheEntry),
+		current:  make(map[string]*CacheEntry),
+	}
+}
+
+// Loads the cache file, if it exists. A cache file from another generator version is ignored.
+func (c *Cache) Load() error {
+	c.previous = make(map[string]*CacheEntry)
+	c.current = make(map[string]*CacheEntry)
+
+	data, err := ioutil.ReadFile(c.Filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	cf := &cacheFile{}
+	err = json.Unmarshal(data, cf)
+	if err != nil {
+		return fmt.Errorf("Error parsing cache file %s: %v", c.Filename, err)
+	}
+
+	if cf.Version == GENERATOR_VERSION && cf.Files != nil {
+		c.previous = cf.Files
+	}
+	return nil
+}
+
+// Returns the entry of the proto file on the loaded cache file
+func (c *Cache) Get(filepath string) (*CacheEntry, bool) {
+	e, ok := c.previous[filepath]
+	return e, ok
+}
+
+// Sets the entry of the proto file for this run
+func (c *Cache) Set(filepath string, entry *CacheEntry) {
+	c.current[filepath] = entry
+}
+
+// Saves the entries of this run
+func (c *Cache) Save() error {
+	data, err := json.MarshalIndent(&cacheFile{
+		Version: GENERATOR_VERSION,
+		Files:   c.current,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(c.Filename, data, 0644)
+}
+
+// Calculates the hash of the inputs of an owned file.
+func (wp *Wrapper) cacheHash(df *fdep.DepFile, ownedFiles []*fdep.DepFile, names *Names) string {
+	h := newCacheHasher()
+
+	h.writeString("version")
+	h.writeString(GENERATOR_VERSION)
+
+	// wrapper settings and plugins
+	h.writeString("settings")
+	h.write(reflect.ValueOf(wp.PkgSource))
+	h.write(reflect.ValueOf(wp.TypeConverters))
+	h.write(reflect.ValueOf(wp.ServiceGen))
+	h.write(reflect.ValueOf(wp.Customizers))
+	h.write(reflect.ValueOf(wp.Files))
+	h.write(reflect.ValueOf(wp.KeepUnknownFields))
+	h.write(reflect.ValueOf(wp.EnumWrap))
+	h.write(reflect.ValueOf(wp.SourceMap))
+	h.write(reflect.ValueOf(wp.PositionComments))
+	h.write(reflect.ValueOf(wp.WrapImported))
+	h.write(reflect.ValueOf(wp.ExternalWrap))
+
+	// renamed identifiers may be referenced from any file
+	h.writeString("names")
+	h.write(reflect.ValueOf(names.renamed))
+
+	// generated files are visible to customizers
+	h.writeString("owned")
+	for _, of := range ownedFiles {
+		h.writeString(of.FilePath)
+	}
+
+	// the file and all its transitive imports, in file path order
+	files := make(map[string]*fdep.DepFile)
+	var collect func(f *fdep.DepFile)
+	collect = func(f *fdep.DepFile) {
+		if _, ok := files[f.FilePath]; ok {
+			return
+		}
+		files[f.FilePath] = f
+
+		for _, imp := range fproto_wrap.FileImports(f) {
+			if impf, ok := wp.dep.Files[imp]; ok {
+				collect(impf)
+			}
+		}
+	}
+	collect(df)
+
+	var filepaths []string
+	for fp := range files {
+		filepaths = append(filepaths, fp)
+	}
+	sort.Strings(filepaths)
+
+	h.writeString("files")
+	h.writeString(df.FilePath)
+	for _, fp := range filepaths {
+		h.writeString(fp)
+		h.write(reflect.ValueOf(files[fp].DepType))
+		h.write(reflect.ValueOf(files[fp].ProtoFile))
+	}
+
+	// the positions of the file elements are written on the position comments and the source map
+	if wp.Positions != nil && (wp.SourceMap || wp.PositionComments) {
+		h.writeString("positions")
+		pg := &Generator{dep: wp.dep}
+		for _, element := range cachePositionElements(df.ProtoFile) {
+			path := pg.elementPath(element)
+			line, column, ok := wp.Positions.GetPosition(df.FilePath, path)
+			h.writeString(path)
+			h.write(reflect.ValueOf(ok))
+			h.write(reflect.ValueOf(line))
+			h.write(reflect.ValueOf(column))
+		}
+	}
+
+	return h.sum()
+}
+
+// Returns the elements of the file that can have a position: enums and their constants, messages and extends, their
+// fields and oneofs, and services and their rpcs.
+func cachePositionElements(pf *fproto.ProtoFile) []fproto.FProtoElement {
+	var ret []fproto.FProtoElement
+
+	addEnum := func(enum *fproto.EnumElement) {
+		ret = append(ret, enum)
+		for _, ec := range enum.EnumConstants {
+			ret = append(ret, ec)
+		}
+	}
+	var addMessage func(message *fproto.MessageElement)
+	addMessage = func(message *fproto.MessageElement) {
+		ret = append(ret, message)
+		for _, fld := range message.Fields {
+			ret = append(ret, fld)
+			if oneof, ok := fld.(*fproto.OneOfFieldElement); ok {
+				for _, oofld := range oneof.Fields {
+					ret = append(ret, oofld)
+				}
+			}
+		}
+		for _, enum := range message.Enums {
+			addEnum(enum)
+		}
+		for _, nested := range message.Messages {
+			addMessage(nested)
+		}
+	}
+
+	for _, enum := range pf.Enums {
+		addEnum(enum)
+	}
+	for _, message := range pf.Messages {
+		addMessage(message)
+	}
+	for _, svc := range pf.Services {
+		ret = append(ret, svc)
+		for _, rpc := range svc.RPCs {
+			ret = append(ret, rpc)
+		}
+	}
+	return ret
+}
+
+// Hashes any value walking it with reflection, in a deterministic order.
+// "Parent" struct fields are skipped, as they point back to already visited elements.
+type cacheHasher struct {
+	h       hash.Hash
+	visited map[uintptr]bool
+}
+
+func newCacheHasher() *cacheHasher {
+	return &cacheHasher{
+		h:       sha256.New(),
+		visited: make(map[uintptr]bool),
+	}
+}
+
+func (c *cacheHasher) sum() string {
+	return hex.EncodeToString(c.h.Sum(nil))
+}
+
+func (c *cacheHasher) writeString(s string) {
+	io.WriteString(c.h, strconv.Quote(s))
+	io.WriteString(c.h, ";")
+}
+
+func (c *cacheHasher) write(v reflect.Value) {
+	if !v.IsValid() {
+		c.writeString("<invalid>")
+		return
+	}
+
+	if v.CanInterface() && (v.Kind() != reflect.Ptr || !v.IsNil()) {
+		if ck, ok := v.Interface().(CacheKey); ok {
+			c.writeString(v.Type().String())
+			c.writeString(ck.CacheKey())
+			return
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			c.writeString("<nil>")
+			return
+		}
+		if c.visited[v.Pointer()] {
+			c.writeString("<visited>")
+			return
+		}
+		c.visited[v.Pointer()] = true
+		c.write(v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			c.writeString("<nil>")
+			return
+		}
+		c.writeString(v.Elem().Type().String())
+		c.write(v.Elem())
+	case reflect.Struct:
+		c.writeString(v.Type().String())
+		for i := 0; i < v.NumField(); i++ {
+			name := v.Type().Field(i).Name
+			if name == "Parent" {
+				continue
+			}
+			c.writeString(name)
+			c.write(v.Field(i))
+		}
+	case reflect.Slice, reflect.Array:
+		c.writeString(strconv.Itoa(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			c.write(v.Index(i))
+		}
+	case reflect.Map:
+		// hash the keys separately to sort them
+		type mapItem struct {
+			key   string
+			value reflect.Value
+		}
+		var items []mapItem
+		for _, k := range v.MapKeys() {
+			kh := newCacheHasher()
+			kh.write(k)
+			items = append(items, mapItem{kh.sum(), v.MapIndex(k)})
+		}
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].key < items[j].key
+		})
+
+		c.writeString(strconv.Itoa(len(items)))
+		for _, item := range items {
+			c.writeString(item.key)
+			c.write(item.value)
+		}
+	case reflect.String:
+		c.writeString(v.String())
+	case reflect.Bool:
+		c.writeString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		c.writeString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		c.writeString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		c.writeString(strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	default:
+		// functions, channels: only the type, see CacheKey
+		c.writeString(v.Type().String())
+	}
+}
@@ -0,0 +1,277 @@
+package fproto_gowrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/RangelReale/fdep"
+	"github.com/RangelReale/fproto-wrap"
+	"gopkg.in/yaml.v2"
+)
+
+// Declarative generator configuration, loaded from a YAML or JSON file.
+// Relative paths are resolved from the config file directory.
+type Config struct {
+	// Include paths for imported files
+	IncludePaths []string `json:"include_paths" yaml:"include_paths"`
+
+	// Proto file paths to parse
+	ProtoPaths []*ConfigProtoPath `json:"proto_paths" yaml:"proto_paths"`
+
+	// Output root path
+	OutputPath string `json:"output_path" yaml:"output_path"`
+
+	// Output file layout
+	Files []*ConfigWrapperFile `json:"files" yaml:"files"`
+
+	// Proto file path => Go wrap package
+	Packages map[string]string `json:"packages" yaml:"packages"`
+
+	// Proto file path => Go wrap file package name
+	FilePackages map[string]string `json:"file_packages" yaml:"file_packages"`
+
+	// Service generator, by registered name
+	ServiceGen *ConfigPlugin `json:"service_gen" yaml:"service_gen"`
+
+	// Type converter plugins, by registered name
+	TypeConverters []*ConfigPlugin `json:"type_converters" yaml:"type_converters"`
+
+	// Customizers, by registered name
+	Customizers []*ConfigPlugin `json:"customizers" yaml:"customizers"`
+
+	// Number of files to generate in parallel, see Wrapper.Concurrency
+	Concurrency int `json:"concurrency" yaml:"concurrency"`
+
+	// File generation order: "path" (default) or "dependency"
+	FileOrder string `json:"file_order" yaml:"file_order"`
+
+	// Enables the incremental generation cache, saved on the output path
+	Cache bool `json:"cache" yaml:"cache"`
+
+	// Keeps the unknown fields of the source messages, see Wrapper.KeepUnknownFields
+	KeepUnknownFields bool `json:"keep_unknown_fields" yaml:"keep_unknown_fields"`
+
+	// Generates the enums as new types with helper methods, see Wrapper.EnumWrap
+	EnumWrap bool `json:"enum_wrap" yaml:"enum_wrap"`
+
+	// Go identifier collision resolution: "error" (default) or "suffix", see NameCollision
+	NameCollision string `json:"name_collision" yaml:"name_collision"`
+
+	// Writes a side-car source map next to each generated file, see Wrapper.SourceMap
+	SourceMap bool `json:"source_map" yaml:"source_map"`
+
+	// Adds the proto positions as comments, see Wrapper.PositionComments
+	PositionComments bool `json:"position_comments" yaml:"position_comments"`
+
+	// Also wraps the imported files used by the owned files, see Wrapper.WrapImported
+	WrapImported bool `json:"wrap_imported" yaml:"wrap_imported"`
+
+	// Proto file path => Go wrap package of the files wrapped outside of this run, see Wrapper.ExternalWrap
+	ExternalWrap map[string]string `json:"external_wrap" yaml:"external_wrap"`
+}
+
+// A proto file path
+type ConfigProtoPath struct {
+	Path string `json:"path" yaml:"path"`
+
+	// If set, the file names are relative to this root instead of Path
+	Root string `json:"root" yaml:"root"`
+
+	// "own" (default) or "imported"
+	DepType string `json:"dep_type" yaml:"dep_type"`
+}
+
+// An output file, see WrapperFile
+type ConfigWrapperFile struct {
+	FileId    string `json:"file_id" yaml:"file_id"`
+	Suffix    string `json:"suffix" yaml:"suffix"`
+	FileAlias string `json:"file_alias" yaml:"file_alias"`
+}
+
+// A registered plugin with its options
+type ConfigPlugin struct {
+	Name    string            `json:"name" yaml:"name"`
+	Options map[string]string `json:"options" yaml:"options"`
+}
+
+// Loads a config file. Files with the ".json" extension are parsed as JSON, all others as YAML.
+func LoadConfig(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &Config{}
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		// reject unknown fields, as yaml.UnmarshalStrict does
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(ret)
+	} else {
+		err = yaml.UnmarshalStrict(data, ret)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing config file %s: %v", filename, err)
+	}
+
+	ret.resolvePaths(filepath.Dir(filename))
+
+	return ret, nil
+}
+
+func (c *Config) resolvePaths(basePath string) {
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(basePath, p)
+	}
+
+	for i, p := range c.IncludePaths {
+		c.IncludePaths[i] = resolve(p)
+	}
+	for _, pp := range c.ProtoPaths {
+		pp.Path = resolve(pp.Path)
+		pp.Root = resolve(pp.Root)
+	}
+	c.OutputPath = resolve(c.OutputPath)
+}
+
+// Parses the proto files
+func (c *Config) ParseDep() (*fdep.Dep, error) {
+	if len(c.ProtoPaths) == 0 {
+		return nil, fmt.Errorf("At least one proto path is required")
+	}
+
+	parsedep := fdep.NewDep()
+	parsedep.IncludeDirs = append(parsedep.IncludeDirs, c.IncludePaths...)
+
+	for _, pp := range c.ProtoPaths {
+		var deptype fdep.FileDepType
+		switch pp.DepType {
+		case "", "own":
+			deptype = fdep.DepType_Own
+		case "imported":
+			deptype = fdep.DepType_Imported
+		default:
+			return nil, fmt.Errorf("Invalid dep_type for proto path %s: %s", pp.Path, pp.DepType)
+		}
+
+		var err error
+		if pp.Root != "" {
+			err = parsedep.AddPathWithRoot(pp.Root, pp.Path, deptype)
+		} else {
+			err = parsedep.AddPath(pp.Path, deptype)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := parsedep.CheckDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	return parsedep, nil
+}
+
+// Returns the directories the proto file paths are relative to: the proto paths, or their roots, and the include
+// paths
+func (c *Config) sourceDirs() []string {
+	var ret []string
+	for _, pp := range c.ProtoPaths {
+		if pp.Root != "" {
+			ret = append(ret, pp.Root)
+		} else {
+			ret = append(ret, pp.Path)
+		}
+	}
+	return append(ret, c.IncludePaths...)
+}
+
+// Creates a wrapper configured with the file layout, package mapping and plugins
+func (c *Config) NewWrapper(dep *fdep.Dep) (*Wrapper, error) {
+	w := NewWrapper(dep)
+	w.Concurrency = c.Concurrency
+	w.KeepUnknownFields = c.KeepUnknownFields
+	w.EnumWrap = c.EnumWrap
+	w.SourceMap = c.SourceMap
+	w.PositionComments = c.PositionComments
+	w.Positions = NewPositionSource_Scan(c.sourceDirs()...)
+	w.WrapImported = c.WrapImported
+	if len(c.ExternalWrap) > 0 {
+		w.ExternalWrap = make(map[string]string)
+		for k, v := range c.ExternalWrap {
+			w.ExternalWrap[k] = v
+		}
+	}
+
+	if c.Cache {
+		w.Cache = NewCache(filepath.Join(c.OutputPath, CACHE_FILENAME))
+	}
+
+	nc, err := ParseNameCollision(c.NameCollision)
+	if err != nil {
+		return nil, err
+	}
+	w.NameCollision = nc
+
+	switch c.FileOrder {
+	case "", "path":
+		w.FileOrder = fproto_wrap.FILEORDER_PATH
+	case "dependency":
+		w.FileOrder = fproto_wrap.FILEORDER_DEPENDENCY
+	default:
+		return nil, fmt.Errorf("Invalid file_order: %s", c.FileOrder)
+	}
+
+	for _, f := range c.Files {
+		w.Files = append(w.Files, &WrapperFile{
+			FileId:    f.FileId,
+			Suffix:    f.Suffix,
+			FileAlias: f.FileAlias,
+		})
+	}
+
+	if len(c.Packages) > 0 || len(c.FilePackages) > 0 {
+		pkgsource := NewPkgSource_Map()
+		for k, v := range c.Packages {
+			pkgsource.Packages[k] = v
+		}
+		for k, v := range c.FilePackages {
+			pkgsource.FilePackages[k] = v
+		}
+		w.PkgSource = pkgsource
+	}
+
+	if c.ServiceGen != nil && c.ServiceGen.Name != "" {
+		sg, err := NewServiceGen(c.ServiceGen.Name, c.ServiceGen.Options)
+		if err != nil {
+			return nil, err
+		}
+		w.ServiceGen = sg
+	}
+
+	for _, p := range c.TypeConverters {
+		tc, err := NewTypeConverterPlugin(p.Name, p.Options)
+		if err != nil {
+			return nil, err
+		}
+		w.TypeConverters = append(w.TypeConverters, tc)
+	}
+
+	for _, p := range c.Customizers {
+		cz, err := NewCustomizer(p.Name, p.Options)
+		if err != nil {
+			return nil, err
+		}
+		w.Customizers = append(w.Customizers, cz)
+	}
+
+	return w, nil
+}
@@ -20,3 +20,19 @@ type Customizer_Global interface {
 	// Allows generation of files independent of an specific proto file
 	GenerateGlobalCode(g *Generator) error
 }
+
+type Customizer_Names interface {
+	// Returns the Go identifiers the customizer generates for a message of the current file, so collisions with
+	// them are detected before the generation, and they are renamed with NAMECOLLISION_SUFFIX.
+	// Use Generator.WrapName to get the identifier to generate.
+	MessageNames(g *Generator, message *fproto.MessageElement) ([]*CustomizerName, error)
+}
+
+// A Go identifier generated by a customizer
+type CustomizerName struct {
+	// Wrapped struct of the identifier, like a method, or blank if it is on the package
+	StructName string
+	Name       string
+	// Description for the collision errors, like "validate function of message 'MyMessage'"
+	Desc string
+}
@@ -1,12 +1,16 @@
 package fproto_gowrap
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
 	"github.com/RangelReale/fdep"
 	"github.com/RangelReale/fproto"
+	"github.com/RangelReale/fproto-wrap"
 )
 
 //
@@ -159,6 +163,16 @@ func (t *TypeConverter_Default) IsPointer() bool {
 	return t.tp.IsPointer()
 }
 
+// Wrapped enums are new types, which are converted by value
+func (t *TypeConverter_Default) ValueOnly() bool {
+	return t.isEnumWrap()
+}
+
+func (t *TypeConverter_Default) isEnumWrap() bool {
+	_, isenum := t.tp.Item.(*fproto.EnumElement)
+	return isenum && t.g.EnumWrap && t.g.IsFileWrap(t.tp.DepFile)
+}
+
 func (t *TypeConverter_Default) GenerateImport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
 	if !g.G().IsFileWrap(t.tp.DepFile) {
 		g.P(varDest, " = ", varSrc)
@@ -172,7 +186,12 @@ func (t *TypeConverter_Default) GenerateImport(g *GeneratorFile, varSrc string,
 
 	switch t.tp.Item.(type) {
 	case *fproto.EnumElement:
-		g.P(varDest, " = ", varSrc)
+		if t.isEnumWrap() {
+			// varDest = goalias.MyEnum(varSrc)
+			g.P(varDest, " = ", t.TypeName(g, TNT_TYPENAME, 0), "(", varSrc, ")")
+		} else {
+			g.P(varDest, " = ", varSrc)
+		}
 		return false, nil
 	}
 
@@ -180,7 +199,7 @@ func (t *TypeConverter_Default) GenerateImport(g *GeneratorFile, varSrc string,
 	goTypeName, _ := g.G().BuildTypeName(t.tp)
 
 	// varDest, err = goalias.MyStruct_Import(varSrc)
-	g.P(varDest, ", err = ", falias, goTypeName, "_Import(", varSrc, ")")
+	g.P(varDest, ", err = ", falias, g.G().wrapName(t.tp.DepFile, "", goTypeName+"_Import"), "(", varSrc, ")")
 
 	return true, nil
 }
@@ -193,12 +212,19 @@ func (t *TypeConverter_Default) GenerateExport(g *GeneratorFile, varSrc string,
 
 	switch t.tp.Item.(type) {
 	case *fproto.EnumElement:
-		g.P(varDest, " = ", varSrc)
+		if t.isEnumWrap() {
+			// varDest = go_package.MyEnum(varSrc)
+			goTypeName, _ := g.G().BuildTypeName(t.tp)
+			g.P(varDest, " = ", g.DeclFileDep(t.tp.DepFile, t.tp.Alias, false), ".", goTypeName, "(", varSrc, ")")
+		} else {
+			g.P(varDest, " = ", varSrc)
+		}
 		return false, nil
 	}
 
 	// varDest, err = MyStruct.Export()
-	g.P(varDest, ", err = ", varSrc, ".Export()")
+	goTypeName, _ := g.G().BuildTypeName(t.tp)
+	g.P(varDest, ", err = ", varSrc, ".", g.G().wrapName(t.tp.DepFile, goTypeName, "Export"), "()")
 	return true, nil
 }
 
@@ -306,30 +332,118 @@ func (c *wrapCustomizers) GenerateServiceCode(g *Generator) error {
 	return nil
 }
 
+//
+// PkgSource: map
+//
+
+// Maps proto file paths to Go wrap packages
+type PkgSource_Map struct {
+	// Proto file path => Go wrap package
+	Packages map[string]string
+
+	// Proto file path => Go wrap file package name (optional)
+	FilePackages map[string]string
+}
+
+func NewPkgSource_Map() *PkgSource_Map {
+	return &PkgSource_Map{
+		Packages:     make(map[string]string),
+		FilePackages: make(map[string]string),
+	}
+}
+
+func (p *PkgSource_Map) GetPkg(g *Generator, depfile *fdep.DepFile) (string, bool) {
+	pkg, ok := p.Packages[depfile.FilePath]
+	return pkg, ok
+}
+
+func (p *PkgSource_Map) GetFilePkg(g *Generator, depfile *fdep.DepFile) (string, bool) {
+	pkg, ok := p.FilePackages[depfile.FilePath]
+	return pkg, ok
+}
+
 //
 // FileOutput: default
 //
 
+// Name of the manifest file written in the output path
+const FILEOUTPUT_MANIFEST = ".fproto-gowrap.manifest"
+
+// Writes the generated files to disk.
+// A manifest of the generated files is saved on the output path, and the files from the previous
+// run that were not generated again are removed.
 type FileOutput_Default struct {
 	OutputPath string
+
+	// Set to false to disable the manifest and the stale files removal
+	Manifest bool
+
+	manifest *fproto_wrap.Manifest
 }
 
 func NewFileOutput_Default(outputPath string) *FileOutput_Default {
 	return &FileOutput_Default{
 		OutputPath: outputPath,
+		Manifest:   true,
 	}
 }
 
 func (f *FileOutput_Default) Initialize() error {
-	return nil
+	f.manifest = nil
+	if !f.Manifest {
+		return nil
+	}
+
+	f.manifest = fproto_wrap.NewManifest(f.OutputPath, FILEOUTPUT_MANIFEST, GENERATED_MARKER)
+	return f.manifest.Load()
 }
 
 func (f *FileOutput_Default) Finalize() error {
-	return nil
+	if f.manifest == nil {
+		return nil
+	}
+
+	err := f.manifest.RemoveStale()
+	if err != nil {
+		return err
+	}
+
+	return f.manifest.Save()
+}
+
+// Keeps a file from the previous run, if it still exists and was generated
+func (f *FileOutput_Default) Keep(filename string) (bool, error) {
+	m := f.manifest
+	if m == nil {
+		m = fproto_wrap.NewManifest(f.OutputPath, FILEOUTPUT_MANIFEST, GENERATED_MARKER)
+	}
+
+	isgen, err := m.IsGenerated(filepath.Join(f.OutputPath, filepath.FromSlash(filename)))
+	if err != nil || !isgen {
+		return false, err
+	}
+
+	if f.manifest != nil {
+		f.manifest.Add(filename)
+	}
+	return true, nil
 }
 
 func (f *FileOutput_Default) Output(g *GeneratorFile) error {
-	p := filepath.Join(f.OutputPath, g.Filename())
+	err := f.writeFile(g.Filename(), g.Output)
+	if err != nil {
+		return err
+	}
+
+	if g.SourceMap {
+		return f.writeFile(g.SourceMapFilename(), g.OutputSourceMap)
+	}
+
+	return nil
+}
+
+func (f *FileOutput_Default) writeFile(filename string, output func(w io.Writer) error) error {
+	p := filepath.Join(f.OutputPath, filename)
 
 	// create paths
 	err := os.MkdirAll(filepath.Dir(p), os.ModePerm)
@@ -345,10 +459,173 @@ func (f *FileOutput_Default) Output(g *GeneratorFile) error {
 	defer file.Close()
 
 	// output contents
-	err = g.Output(file)
+	err = output(file)
 	if err != nil {
 		return err
 	}
 
+	if f.manifest != nil {
+		f.manifest.Add(filename)
+	}
+
+	return nil
+}
+
+//
+// FileOutput: memory
+//
+
+// Collects the generated files in memory, keyed by filename, without touching the filesystem.
+// The files of the previous run are removed on Initialize.
+type FileOutput_Memory struct {
+	*fproto_wrap.MemoryFiles
+}
+
+func NewFileOutput_Memory() *FileOutput_Memory {
+	return &FileOutput_Memory{
+		MemoryFiles: fproto_wrap.NewMemoryFiles(),
+	}
+}
+
+func (f *FileOutput_Memory) Initialize() error {
+	f.Reset()
+	return nil
+}
+
+func (f *FileOutput_Memory) Finalize() error {
 	return nil
 }
+
+func (f *FileOutput_Memory) Output(g *GeneratorFile) error {
+	var b bytes.Buffer
+
+	// output contents
+	err := g.Output(&b)
+	if err != nil {
+		return err
+	}
+
+	f.Set(g.Filename(), b.Bytes())
+
+	if g.SourceMap {
+		var sm bytes.Buffer
+		err = g.OutputSourceMap(&sm)
+		if err != nil {
+			return err
+		}
+		f.Set(g.SourceMapFilename(), sm.Bytes())
+	}
+	return nil
+}
+
+//
+// FileOutput: check
+//
+
+// A difference found by FileOutput_Check
+type CheckDifference struct {
+	Filename string
+	// "changed", "missing" (not on disk) or "extra" (on disk, listed on the manifest, but not generated)
+	Kind string
+	// Unified diff from the file on disk to the generated one
+	Diff string
+}
+
+// Generates the files in memory and compares them with the files on the output path, without writing to disk.
+// Finalize returns an error if any file is changed, missing, or extra.
+type FileOutput_Check struct {
+	OutputPath string
+
+	// If not nil, the unified diffs are written to it
+	DiffOutput io.Writer
+
+	memory      *FileOutput_Memory
+	differences []*CheckDifference
+}
+
+func NewFileOutput_Check(outputPath string, diffOutput io.Writer) *FileOutput_Check {
+	return &FileOutput_Check{
+		OutputPath: outputPath,
+		DiffOutput: diffOutput,
+	}
+}
+
+func (f *FileOutput_Check) Initialize() error {
+	f.memory = NewFileOutput_Memory()
+	f.differences = nil
+	return nil
+}
+
+func (f *FileOutput_Check) Finalize() error {
+	err := f.memory.Each(func(filename string, content []byte) error {
+		current, err := ioutil.ReadFile(filepath.Join(f.OutputPath, filepath.FromSlash(filename)))
+		if os.IsNotExist(err) {
+			f.addDifference(filename, "missing", fproto_wrap.UnifiedDiff("/dev/null", filename, nil, content))
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if diff := fproto_wrap.UnifiedDiff(filename, filename, current, content); diff != "" {
+			f.addDifference(filename, "changed", diff)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// files from the last run that would be removed as stale
+	manifest := fproto_wrap.NewManifest(f.OutputPath, FILEOUTPUT_MANIFEST, GENERATED_MARKER)
+	err = manifest.Load()
+	if err != nil {
+		return err
+	}
+	for _, filename := range f.memory.Filenames() {
+		manifest.Add(filename)
+	}
+
+	for _, filename := range manifest.Stale() {
+		p := filepath.Join(f.OutputPath, filepath.FromSlash(filename))
+
+		isgen, err := manifest.IsGenerated(p)
+		if err != nil {
+			return err
+		}
+		if !isgen {
+			continue
+		}
+
+		current, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		f.addDifference(filename, "extra", fproto_wrap.UnifiedDiff(filename, "/dev/null", current, nil))
+	}
+
+	if len(f.differences) > 0 {
+		return fmt.Errorf("%d generated file(s) are out of date", len(f.differences))
+	}
+	return nil
+}
+
+func (f *FileOutput_Check) Output(g *GeneratorFile) error {
+	return f.memory.Output(g)
+}
+
+// Returns the differences found on Finalize
+func (f *FileOutput_Check) Differences() []*CheckDifference {
+	return f.differences
+}
+
+func (f *FileOutput_Check) addDifference(filename string, kind string, diff string) {
+	f.differences = append(f.differences, &CheckDifference{
+		Filename: filename,
+		Kind:     kind,
+		Diff:     diff,
+	})
+
+	if f.DiffOutput != nil {
+		fmt.Fprintf(f.DiffOutput, "%s: %s\n%s", filename, kind, diff)
+	}
+}
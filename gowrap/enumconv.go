@@ -0,0 +1,171 @@
+package fproto_gowrap
+
+import (
+	"fmt"
+
+	"github.com/RangelReale/fdep"
+	"github.com/RangelReale/fproto"
+)
+
+// Maps proto enums to application-defined Go enum types.
+type EnumTypeConverter interface {
+	// Returns the Go enum type of the proto enum, or nil if the enum is not mapped
+	GetEnumType(tp *fdep.DepType) *EnumType
+}
+
+// An application-defined Go enum type
+type EnumType struct {
+	// Import path of the package of the type
+	Package string
+	// Default package alias. If blank, the last path component is used.
+	Alias string
+	// Go type name
+	TypeName string
+	// Proto enum constant name => Go constant name, on the same package. All constants must be mapped.
+	Values map[string]string
+}
+
+//
+// TypeConverterPlugin: Enum
+//
+
+// Type converter plugin that maps enums using an EnumTypeConverter.
+// The enums are converted using generated switch statements, so invalid values are reported as errors.
+type TypeConverterPlugin_Enum struct {
+	EnumTypeConverter EnumTypeConverter
+}
+
+func NewTypeConverterPlugin_Enum(enumTypeConverter EnumTypeConverter) *TypeConverterPlugin_Enum {
+	return &TypeConverterPlugin_Enum{
+		EnumTypeConverter: enumTypeConverter,
+	}
+}
+
+func (t *TypeConverterPlugin_Enum) GetTypeConverter(tp *fdep.DepType) TypeConverter {
+	enum, ok := tp.Item.(*fproto.EnumElement)
+	if !ok {
+		return nil
+	}
+
+	et := t.EnumTypeConverter.GetEnumType(tp)
+	if et == nil {
+		return nil
+	}
+
+	return &TypeConverter_Enum{
+		tp:       tp,
+		enum:     enum,
+		enumType: et,
+	}
+}
+
+//
+// TypeConverter: Enum
+//
+
+const (
+	TCID_ENUM TCID = "0f0d2d1c-3cb4-4e2f-a4d9-1f5b0e6b8a57"
+)
+
+// Type converter for enums mapped to an application-defined Go enum type
+type TypeConverter_Enum struct {
+	tp       *fdep.DepType
+	enum     *fproto.EnumElement
+	enumType *EnumType
+}
+
+func (t *TypeConverter_Enum) TCID() TCID {
+	return TCID_ENUM
+}
+
+func (t *TypeConverter_Enum) TypeName(g *GeneratorFile, tntype TypeNameType, options uint32) string {
+	var ret string
+
+	switch tntype {
+	case TNT_FIELD_DEFINITION:
+		if g.G().Syntax() == GeneratorSyntax_Proto2 && t.tp.CanPointer() {
+			ret += "*"
+		}
+	}
+
+	alias := g.DeclDep(t.enumType.Package, t.enumType.Alias)
+	return ret + alias + "." + t.enumType.TypeName
+}
+
+func (t *TypeConverter_Enum) IsPointer() bool {
+	return false
+}
+
+func (t *TypeConverter_Enum) ValueOnly() bool {
+	return true
+}
+
+func (t *TypeConverter_Enum) GenerateImport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	src_alias := g.DeclFileDep(t.tp.DepFile, t.tp.Alias, false)
+	dest_alias := g.DeclDep(t.enumType.Package, t.enumType.Alias)
+	fmt_alias := g.DeclDep("fmt", "fmt")
+
+	// proto constants with the same value (aliases) are imported as the first one
+	done := make(map[int]bool)
+
+	g.P("switch ", varSrc, " {")
+	for _, ec := range t.enum.EnumConstants {
+		ecGoName, _ := g.G().BuildEnumConstantName(ec)
+		destName, ok := t.enumType.Values[ec.Name]
+		if !ok {
+			return false, fmt.Errorf("Enum constant %s.%s is not mapped to a value of %s.%s", t.tp.Name, ec.Name,
+				t.enumType.Package, t.enumType.TypeName)
+		}
+		if done[ec.Tag] {
+			continue
+		}
+		done[ec.Tag] = true
+
+		g.P("case ", src_alias, ".", ecGoName, ":")
+		g.In()
+		g.P(varDest, " = ", dest_alias, ".", destName)
+		g.Out()
+	}
+	g.P("default:")
+	g.In()
+	g.P(varError, " = ", fmt_alias, ".Errorf(\"Invalid value %d for enum ", t.tp.Name, "\", ", varSrc, ")")
+	g.Out()
+	g.P("}")
+
+	return true, nil
+}
+
+func (t *TypeConverter_Enum) GenerateExport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	src_alias := g.DeclDep(t.enumType.Package, t.enumType.Alias)
+	dest_alias := g.DeclFileDep(t.tp.DepFile, t.tp.Alias, false)
+	fmt_alias := g.DeclDep("fmt", "fmt")
+
+	// Go constants mapped from more than one proto constant (aliases) are exported as the first one
+	done := make(map[string]bool)
+
+	g.P("switch ", varSrc, " {")
+	for _, ec := range t.enum.EnumConstants {
+		ecGoName, _ := g.G().BuildEnumConstantName(ec)
+		srcName, ok := t.enumType.Values[ec.Name]
+		if !ok {
+			return false, fmt.Errorf("Enum constant %s.%s is not mapped to a value of %s.%s", t.tp.Name, ec.Name,
+				t.enumType.Package, t.enumType.TypeName)
+		}
+		if done[srcName] {
+			continue
+		}
+		done[srcName] = true
+
+		g.P("case ", src_alias, ".", srcName, ":")
+		g.In()
+		g.P(varDest, " = ", dest_alias, ".", ecGoName)
+		g.Out()
+	}
+	g.P("default:")
+	g.In()
+	g.P(varError, " = ", fmt_alias, ".Errorf(\"Invalid value %v for enum ", t.tp.Name, "\", ", varSrc, ")")
+	g.Out()
+	g.P("}")
+
+	return true, nil
+}
@@ -0,0 +1,141 @@
+package fproto_gowrap
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/RangelReale/fdep"
+)
+
+const testEnumProto = `syntax = "proto3";
+package enumtest;
+option go_package = "example.com/enumtest";
+
+enum Color {
+  COLOR_RED = 0;
+  COLOR_GREEN = 1;
+}
+
+message Paint {
+  Color color = 1;
+}
+`
+
+// Maps the enums to the values of a Go enum type
+type testEnumTypeConverter struct {
+	values map[string]string
+}
+
+func (c *testEnumTypeConverter) GetEnumType(tp *fdep.DepType) *EnumType {
+	if tp.Name != "Color" {
+		return nil
+	}
+	return &EnumType{
+		Package:  "example.com/app/color",
+		TypeName: "Color",
+		Values:   c.values,
+	}
+}
+
+// Generates the test proto file, returning the generated code of all files
+func testEnumGenerate(t *testing.T, setup func(w *Wrapper)) (string, error) {
+	dep := fdep.NewDep()
+	if err := dep.AddReader("enumtest.proto", strings.NewReader(testEnumProto), fdep.DepType_Own); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWrapper(dep)
+	setup(w)
+
+	output := NewFileOutput_Memory()
+	if err := w.Generate(output); err != nil {
+		return "", err
+	}
+
+	var filenames []string
+	files := output.Files()
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	var ret []string
+	for _, filename := range filenames {
+		ret = append(ret, string(files[filename]))
+	}
+	return strings.Join(ret, "\n"), nil
+}
+
+// Checks that the code contains each of the lines, ignoring indentation
+func testContainsLines(t *testing.T, code string, lines ...string) {
+	trimmed := make(map[string]bool)
+	for _, l := range strings.Split(code, "\n") {
+		trimmed[strings.TrimSpace(l)] = true
+	}
+	for _, l := range lines {
+		if !trimmed[l] {
+			t.Errorf("line not generated: %s", l)
+		}
+	}
+}
+
+func TestEnumWrap(t *testing.T) {
+	code, err := testEnumGenerate(t, func(w *Wrapper) {
+		w.EnumWrap = true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testContainsLines(t, code,
+		"type Color int32",
+		"func ParseColor(s string) (Color, error) {",
+		"if v, ok := Color_value[s]; ok {",
+		`return 0, fmt.Errorf("Invalid value for enum Color: %q", s)`,
+		"func Color_Values() []Color {",
+		"func (x Color) IsValid() bool {",
+		"func (x *Color) UnmarshalText(text []byte) error {",
+	)
+
+	// the values are listed in declaration order
+	if !strings.Contains(code, "return []Color{\n\t\tColor_COLOR_RED,\n\t\tColor_COLOR_GREEN,\n\t}") {
+		t.Errorf("unexpected values list:\n%s", code)
+	}
+}
+
+func TestEnumTypeConverter(t *testing.T) {
+	values := map[string]string{
+		"COLOR_RED":   "Red",
+		"COLOR_GREEN": "Green",
+	}
+	code, err := testEnumGenerate(t, func(w *Wrapper) {
+		w.TypeConverters = []TypeConverterPlugin{NewTypeConverterPlugin_Enum(&testEnumTypeConverter{values: values})}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testContainsLines(t, code,
+		"Color color.Color",
+		"ret.Color = color.Red",
+		"ret.Color = color.Green",
+		// unknown values are import errors
+		`err = fmt.Errorf("Invalid value %d for enum Color", s.Color)`,
+		`err = fmt.Errorf("Invalid value %v for enum Color", m.Color)`,
+	)
+}
+
+func TestEnumTypeConverterNotMapped(t *testing.T) {
+	_, err := testEnumGenerate(t, func(w *Wrapper) {
+		w.TypeConverters = []TypeConverterPlugin{NewTypeConverterPlugin_Enum(&testEnumTypeConverter{
+			values: map[string]string{"COLOR_RED": "Red"},
+		})}
+	})
+	if err == nil {
+		t.Fatal("expected a not mapped error")
+	}
+	if !strings.Contains(err.Error(), "Enum constant Color.COLOR_GREEN is not mapped to a value of example.com/app/color.Color") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package fproto_gowrap
+
+import (
+	"fmt"
+
+	"github.com/RangelReale/fproto"
+)
+
+// Error generating a proto file, with the element that caused it
+type GenerationError struct {
+	// Proto file path
+	File string
+	// Path of the element on the proto file, like "Message.field". Blank if the error is not from an element.
+	Element string
+	// Position of the element on the proto file, starting at 1, or 0 if not known. Only set if the generator has a
+	// PositionSource.
+	Line   int
+	Column int
+	// The original error
+	Err error
+}
+
+// Formats the error as "file:line:col: element: message"
+func (e *GenerationError) Error() string {
+	pos := e.File
+	if e.Line > 0 {
+		pos += fmt.Sprintf(":%d", e.Line)
+		if e.Column > 0 {
+			pos += fmt.Sprintf(":%d", e.Column)
+		}
+	}
+	if e.Element != "" {
+		return fmt.Sprintf("%s: %s: %v", pos, e.Element, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", pos, e.Err)
+}
+
+// Returns the original error
+func (e *GenerationError) Unwrap() error {
+	return e.Err
+}
+
+// Wraps the error with the file and the element. Errors that are already a GenerationError are returned unchanged,
+// so the innermost element is reported.
+func (g *Generator) elementError(element fproto.FProtoElement, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*GenerationError); ok {
+		return err
+	}
+
+	ret := &GenerationError{
+		Err: err,
+	}
+	if g.depfile != nil {
+		ret.File = g.depfile.FilePath
+	}
+	if element != nil {
+		ret.Element = g.elementPath(element)
+		ret.Line, ret.Column = g.elementPosition(ret.File, ret.Element)
+	}
+	return ret
+}
+
+// Returns the position of the element on the proto file from the PositionSource, or 0 if not known
+func (g *Generator) elementPosition(file string, element string) (line int, column int) {
+	if g.Positions == nil || file == "" || element == "" {
+		return 0, 0
+	}
+	line, column, ok := g.Positions.GetPosition(file, element)
+	if !ok {
+		return 0, 0
+	}
+	return line, column
+}
+
+// Wraps the error with the file
+func (g *Generator) fileError(err error) error {
+	return g.elementError(nil, err)
+}
+
+// Returns the path of the element on the proto file, like "Message.field", "Enum.VALUE", "Service.Method" or
+// "extend Message.field"
+func (g *Generator) elementPath(element fproto.FProtoElement) string {
+	if extend, ok := element.(*fproto.MessageElement); ok && extend.IsExtend {
+		return "extend " + extend.Name
+	}
+	if tp := g.dep.DepTypeFromElement(element); tp != nil {
+		return tp.Name
+	}
+
+	// elements without a dep type are named inside their parent
+	var name string
+	switch el := element.(type) {
+	case fproto.FieldElementTag:
+		name = el.FieldName()
+	case *fproto.EnumConstantElement, *fproto.RPCElement, *fproto.ServiceElement:
+		name = el.ElementName()
+	default:
+		return ""
+	}
+	if parent := element.ParentElement(); parent != nil {
+		if pp := g.elementPath(parent); pp != "" {
+			return pp + "." + name
+		}
+	}
+	return name
+}
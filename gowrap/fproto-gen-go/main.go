@@ -2,10 +2,12 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
-	"github.com/RangelReale/fdep"
+	"github.com/RangelReale/fproto-wrap"
 	"github.com/RangelReale/fproto-wrap/gowrap"
 )
 
@@ -23,40 +25,61 @@ func (i *arrayFlags) Set(value string) error {
 
 // command line flags
 var (
-	incPaths   = arrayFlags{}
-	protoPaths = arrayFlags{}
-	outputPath = flag.String("output_path", "", "Output root path")
+	incPaths       = arrayFlags{}
+	protoPaths     = arrayFlags{}
+	typeConverters = arrayFlags{}
+	customizers    = arrayFlags{}
+	externalWraps  = arrayFlags{}
+	configFile     = flag.String("config", "", "YAML or JSON config file (the other flags are added to it)")
+	outputPath     = flag.String("output_path", "", "Output root path")
+	serviceGen     = flag.String("service_gen", "", "Service generator, as name or name:key=value,... (ex: grpc)")
+	check          = flag.Bool("check", false, "Check if the files on the output path are up to date, without writing them")
+	listPlugins    = flag.Bool("list_plugins", false, "List the registered plugins and exit")
+	concurrency    = flag.Int("concurrency", 0, "Number of files to generate in parallel")
+	cache          = flag.Bool("cache", false, "Don't generate again the files whose inputs didn't change since the previous run")
+	keepUnknown    = flag.Bool("keep_unknown_fields", false, "Keep the unknown fields of the source messages through Import/Export")
+	enumWrap       = flag.Bool("enum_wrap", false, "Generate the enums as new types with helper methods instead of aliases")
+	nameCollision  = flag.String("name_collision", "", "Go identifier collision resolution: error (default) or suffix")
+	sourceMap      = flag.Bool("source_map", false, "Write a source map (.map.json) linking each generated file to the proto elements")
+	posComments    = flag.Bool("position_comments", false, "Add the proto positions as comments before each generated element")
+	wrapImported   = flag.Bool("wrap_imported", false, "Also wrap the imported proto files used by the application files")
 )
 
 // Usage:
 // fproto-gen-go -inc_path="/protoc-3.5.1/include" -inc_path="/otherproto/include" -proto_path="/mysource/proto" -output_path="/mysource/proto_wrappers"
+// fproto-gen-go -config="/mysource/fproto-gen-go.yaml"
+// fproto-gen-go -check -proto_path="/mysource/proto" -output_path="/mysource/proto_wrappers"
+// fproto-gen-go -service_gen=grpc -customizer="jsontag" -type_converter="uuid:format=string" -proto_path="/mysource/proto" -output_path="/mysource/proto_wrappers"
+// fproto-gen-go -list_plugins
 func main() {
 	// parse command line flags
 	flag.Var(&incPaths, "inc_path", "Include paths (can be set multiple times)")
 	flag.Var(&protoPaths, "proto_path", "Application protocol buffers paths (can be set multiple times)")
+	flag.Var(&typeConverters, "type_converter", "Type converter plugin, as name or name:key=value,... (can be set multiple times)")
+	flag.Var(&customizers, "customizer", "Customizer, as name or name:key=value,... (can be set multiple times)")
+	flag.Var(&externalWraps, "external_wrap", "Go wrap package of a proto file wrapped outside of this run, as file=package (can be set multiple times)")
 	flag.Parse()
 
-	// check parameters
-	if len(protoPaths) == 0 {
-		log.Fatal("At least one proto path is required")
-	}
-
-	if *outputPath == "" {
-		log.Fatal("The output path is required")
+	if *listPlugins {
+		fmt.Printf("type converters: %s\n", strings.Join(fproto_gowrap.ListTypeConverterPlugins(), ", "))
+		fmt.Printf("customizers: %s\n", strings.Join(fproto_gowrap.ListCustomizers(), ", "))
+		fmt.Printf("service generators: %s\n", strings.Join(fproto_gowrap.ListServiceGens(), ", "))
+		return
 	}
 
-	// create output path
-	if err := os.MkdirAll(*outputPath, os.ModePerm); err != nil {
-		log.Fatalf("Error creating output_path '%s': %v", *outputPath, err)
+	// load the config file
+	config := &fproto_gowrap.Config{}
+	if *configFile != "" {
+		var err error
+		config, err = fproto_gowrap.LoadConfig(*configFile)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	// creates a new fdep.Dep to parse the proto files
-	parsedep := fdep.NewDep()
-
-	// add include directories
-	parsedep.IncludeDirs = append(parsedep.IncludeDirs, incPaths...)
+	// add the command line flags to the config
+	config.IncludePaths = append(config.IncludePaths, incPaths...)
 
-	// add own source code
 	for _, protoPath := range protoPaths {
 		if s, err := os.Stat(protoPath); err != nil {
 			log.Fatalf("Error reading proto_path: %v", err)
@@ -64,43 +87,130 @@ func main() {
 			log.Fatalf("proto_path isn't a directory: %s", protoPath)
 		}
 
-		err := parsedep.AddPath(protoPath, fdep.DepType_Own)
-		if err != nil {
-			log.Fatal(err)
+		config.ProtoPaths = append(config.ProtoPaths, &fproto_gowrap.ConfigProtoPath{Path: protoPath})
+	}
+
+	if *outputPath != "" {
+		config.OutputPath = *outputPath
+	}
+
+	if *cache {
+		config.Cache = true
+	}
+
+	if *keepUnknown {
+		config.KeepUnknownFields = true
+	}
+
+	if *enumWrap {
+		config.EnumWrap = true
+	}
+
+	if *sourceMap {
+		config.SourceMap = true
+	}
+
+	if *posComments {
+		config.PositionComments = true
+	}
+
+	if *wrapImported {
+		config.WrapImported = true
+	}
+
+	for _, ew := range externalWraps {
+		i := strings.Index(ew, "=")
+		if i <= 0 {
+			log.Fatalf("Invalid external_wrap, must be file=package: %s", ew)
+		}
+		if config.ExternalWrap == nil {
+			config.ExternalWrap = make(map[string]string)
+		}
+		config.ExternalWrap[ew[:i]] = ew[i+1:]
+	}
+
+	if *nameCollision != "" {
+		config.NameCollision = *nameCollision
+	}
+
+	if *concurrency > 0 {
+		config.Concurrency = *concurrency
+	}
+
+	if *serviceGen != "" {
+		config.ServiceGen = parsePluginFlag(*serviceGen)
+	}
+
+	for _, tc := range typeConverters {
+		config.TypeConverters = append(config.TypeConverters, parsePluginFlag(tc))
+	}
+
+	for _, cz := range customizers {
+		config.Customizers = append(config.Customizers, parsePluginFlag(cz))
+	}
+
+	// check parameters
+	if len(config.ProtoPaths) == 0 {
+		log.Fatal("At least one proto path is required")
+	}
+
+	if config.OutputPath == "" {
+		log.Fatal("The output path is required")
+	}
+
+	// create output path
+	if !*check {
+		if err := os.MkdirAll(config.OutputPath, os.ModePerm); err != nil {
+			log.Fatalf("Error creating output_path '%s': %v", config.OutputPath, err)
 		}
 	}
 
-	// check for missing dependencies
-	err := parsedep.CheckDependencies()
+	// parse the proto files and check for missing dependencies
+	parsedep, err := config.ParseDep()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// creates the wrapper generator, with the type converters, customizers and service generator
+	// enabled on the config.
+	// Plugins from other packages must be registered with fproto_gowrap.RegisterTypeConverterPlugin,
+	// fproto_gowrap.RegisterCustomizer and fproto_gowrap.RegisterServiceGen, so to use them build a copy
+	// of this executable that imports the plugin packages.
+	w, err := config.NewWrapper(parsedep)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// creates the wrapper generator
-	w := fproto_gowrap.NewWrapper(parsedep)
-
-	// Add type converters
-	/*
-		w.TypeConverters = append(w.TypeConverters,
-			&fprotostd_gowrap_uuid.TypeConverterPlugin_UUID{},
-			&fprotostd_gowrap_time.TypeConverterPlugin_Time{},
-			&fprotostd_gowrap_duration.TypeConverterPlugin_Duration{},
-			&fprotostd_gowrap_jsonobject.TypeConverterPlugin_JSONObject{},
-		)
-	*/
-
-	// Add Customizers
-	/*
-		w.Customizers = append(w.Customizers,
-			&fprotostd_gowrap_sqltag.Customizer_SQLTag{},
-			&fprotostd_gowrap_jsontag.Customizer_JSONTag{},
-		)
-		w.ServiceGen = fproto_gowrap.NewServiceGen_gRPC()
-	*/
+	// check the wrapper files
+	if *check {
+		err = w.Generate(fproto_gowrap.NewFileOutput_Check(config.OutputPath, os.Stdout))
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
 
 	// generate the wrapper files
-	err = w.GenerateFiles(*outputPath)
+	err = w.GenerateFiles(config.OutputPath)
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// Prints the error and exits. Generation errors are printed as "file:line:col: message", without the log prefix,
+// so editors and build tools can jump to the proto element.
+func fatal(err error) {
+	if gerr, ok := err.(*fproto_gowrap.GenerationError); ok {
+		fmt.Fprintln(os.Stderr, gerr.Error())
+		os.Exit(1)
+	}
+	log.Fatal(err)
+}
+
+func parsePluginFlag(spec string) *fproto_gowrap.ConfigPlugin {
+	name, options, err := fproto_wrap.ParsePluginSpec(spec)
 	if err != nil {
 		log.Fatal(err)
 	}
+	return &fproto_gowrap.ConfigPlugin{Name: name, Options: options}
 }
@@ -4,7 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/RangelReale/fdep"
 	"github.com/RangelReale/fproto"
@@ -47,6 +50,32 @@ type Generator struct {
 
 	// Customizers
 	Customizers []Customizer
+
+	// Files generated on the current Wrapper run, in generation order: the owned files, and the imported files
+	// when WrapImported is set
+	OwnedFiles []*fdep.DepFile
+
+	// Wraps the messages of the imported files too, see Wrapper.WrapImported
+	WrapImported bool
+
+	// Proto file path => Go wrap package of the files wrapped outside of this run, see Wrapper.ExternalWrap
+	ExternalWrap map[string]string
+
+	// Keeps the unknown fields of the source messages, see Wrapper.KeepUnknownFields
+	KeepUnknownFields bool
+
+	// Generates enums as new types with helper methods, see Wrapper.EnumWrap
+	EnumWrap bool
+
+	// Go identifiers of the owned files, with the ones renamed to avoid collisions. If nil, nothing is renamed.
+	Names *Names
+
+	// Default of the GeneratorFile options of the same name, for the files created after they are set
+	SourceMap        bool
+	PositionComments bool
+
+	// Positions of the proto elements, see Wrapper.Positions
+	Positions PositionSource
 }
 
 // Creates a new generator for the file path.
@@ -105,6 +134,23 @@ func (g *Generator) F(fileId string) *GeneratorFile {
 	return nil
 }
 
+// Returns the files that have data, in file id order
+func (g *Generator) DataFiles() []*GeneratorFile {
+	var fileIds []string
+	for fileId, gf := range g.Files {
+		if gf != nil && gf.HaveData() {
+			fileIds = append(fileIds, fileId)
+		}
+	}
+	sort.Strings(fileIds)
+
+	var ret []*GeneratorFile
+	for _, fileId := range fileIds {
+		ret = append(ret, g.Files[fileId])
+	}
+	return ret
+}
+
 // Helper to get the MAIN file
 func (g *Generator) FMain() *GeneratorFile {
 	return g.F(FILEID_MAIN)
@@ -136,9 +182,14 @@ func (g *Generator) GetDepFile() *fdep.DepFile {
 	return g.depfile
 }
 
-// Check if the file should be wrapped (the file option fproto_wrap.wrap=false disables it)
+// Check if the file should be wrapped (the file option fproto_wrap.wrap=false disables it).
+// Imported files are only wrapped if WrapImported is set or if they have an external wrap package.
 func (g *Generator) IsFileWrap(depfile *fdep.DepFile) bool {
-	if depfile.DepType != fdep.DepType_Own {
+	if _, ok := g.ExternalWrap[depfile.FilePath]; ok {
+		return true
+	}
+
+	if depfile.DepType != fdep.DepType_Own && !(g.WrapImported && IsImportWrappable(depfile)) {
 		return false
 	}
 
@@ -151,6 +202,20 @@ func (g *Generator) IsFileWrap(depfile *fdep.DepFile) bool {
 	return true
 }
 
+// Check if the wrapper of the file is generated on this run, that is, if it is wrapped and has no external wrap package
+func (g *Generator) IsFileGenerate(depfile *fdep.DepFile) bool {
+	if _, ok := g.ExternalWrap[depfile.FilePath]; ok {
+		return false
+	}
+	return g.IsFileWrap(depfile)
+}
+
+// Check if an imported file can be wrapped with Wrapper.WrapImported. The well-known types files (google/protobuf)
+// are never wrapped, type converters are used for them.
+func IsImportWrappable(depfile *fdep.DepFile) bool {
+	return !strings.HasPrefix(depfile.FilePath, "google/protobuf/")
+}
+
 // Executes the generator
 func (g *Generator) Generate() error {
 	// CUSTOMIZER
@@ -169,7 +234,7 @@ func (g *Generator) Generate() error {
 	// CUSTOMIZER
 	err = cz.GenerateCode(g)
 	if err != nil {
-		return err
+		return g.fileError(err)
 	}
 
 	err = g.GenerateServices()
@@ -180,7 +245,7 @@ func (g *Generator) Generate() error {
 	// CUSTOMIZER
 	err = cz.GenerateServiceCode(g)
 	if err != nil {
-		return err
+		return g.fileError(err)
 	}
 
 	return nil
@@ -189,10 +254,12 @@ func (g *Generator) Generate() error {
 // Generates the protobuf enums
 func (g *Generator) GenerateEnums() error {
 	for _, enum := range g.depfile.ProtoFile.CollectEnums() {
+		g.BeginElement(enum)
 		err := g.generateEnum(enum.(*fproto.EnumElement))
 		if err != nil {
-			return err
+			return g.elementError(enum, err)
 		}
+		g.EndElement()
 	}
 	return nil
 }
@@ -200,10 +267,12 @@ func (g *Generator) GenerateEnums() error {
 // Generates the protobuf messages
 func (g *Generator) GenerateMessages() error {
 	for _, message := range g.depfile.ProtoFile.CollectMessages() {
+		g.BeginElement(message)
 		err := g.generateMessage(message.(*fproto.MessageElement))
 		if err != nil {
-			return err
+			return g.elementError(message, err)
 		}
+		g.EndElement()
 	}
 	return nil
 }
@@ -215,14 +284,30 @@ func (g *Generator) GenerateServices() error {
 	}
 
 	for _, svc := range g.depfile.ProtoFile.CollectServices() {
+		g.BeginElement(svc)
 		err := g.ServiceGen.GenerateService(g, svc.(*fproto.ServiceElement))
 		if err != nil {
-			return err
+			return g.elementError(svc, err)
 		}
+		g.EndElement()
 	}
 	return nil
 }
 
+// Starts a source map region of the element on all files, see GeneratorFile.BeginElement
+func (g *Generator) BeginElement(element fproto.FProtoElement) {
+	for _, gf := range g.Files {
+		gf.BeginElement(element)
+	}
+}
+
+// Ends the last source map region started on all files
+func (g *Generator) EndElement() {
+	for _, gf := range g.Files {
+		gf.EndElement()
+	}
+}
+
 // Builds the message name.
 // Given the proto:
 // 		message A_msg { message B_test { string field; } }
@@ -242,6 +327,15 @@ func (g *Generator) BuildMessageName(message *fproto.MessageElement) (goName str
 	return
 }
 
+// Builds the full proto name of the message, including the package, like "mypackage.A_msg.B_test".
+func (g *Generator) BuildMessageFullName(message *fproto.MessageElement) string {
+	_, protoName := g.BuildMessageName(message)
+	if g.depfile.ProtoFile.PackageName == "" {
+		return protoName
+	}
+	return g.depfile.ProtoFile.PackageName + "." + protoName
+}
+
 // Builds the field name.
 func (g *Generator) BuildFieldName(field fproto.FieldElementTag) (goName string, protoName string) {
 	goName = fproto_wrap.CamelCase(field.FieldName())
@@ -253,7 +347,7 @@ func (g *Generator) BuildFieldName(field fproto.FieldElementTag) (goName string,
 // Generates a message
 func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 	if message.IsExtend {
-		return nil
+		return g.generateExtend(message)
 	}
 
 	// build aliases to the original type
@@ -262,7 +356,17 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 	// get the message DepType
 	tp_msg := g.dep.DepTypeFromElement(message)
 	if tp_msg == nil {
-		return errors.New("message type not found")
+		return g.elementError(message, errors.New("message type not found"))
+	}
+
+	// only singular fields are supported inside oneofs
+	for _, fld := range message.Fields {
+		if oneof, isoneof := fld.(*fproto.OneOfFieldElement); isoneof {
+			err := g.checkOneOfFields(tp_msg, oneof)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	// get the type names
@@ -282,12 +386,14 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 	g.FMain().In()
 
 	for _, fld := range message.Fields {
+		g.FMain().BeginElement(fld)
+
 		// CUSTOMIZER
 		field_tag := NewStructTag()
 
 		err := cz.GetTag(g, field_tag, message, fld)
 		if err != nil {
-			return err
+			return g.elementError(fld, err)
 		}
 
 		fldGoName, _ := g.BuildFieldName(fld)
@@ -299,7 +405,7 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 
 			tinfo, err := g.GetTypeInfoFromParent(tp_msg, xfld.Type)
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
 
 			var type_prefix string
@@ -310,18 +416,23 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 				tctn = TNT_TYPENAME
 			}
 
-			g.FMain().P(fldGoName, " ", type_prefix, tinfo.Converter().TypeName(g.FMain(), tctn, 0), field_tag.OutputWithSpace())
+			fieldType := type_prefix + tinfo.Converter().TypeName(g.FMain(), tctn, 0)
+			if g.isProto3Optional(tinfo, xfld) {
+				fieldType = g.optionalFieldType(g.FMain(), tinfo.Converter())
+			}
+
+			g.FMain().P(fldGoName, " ", fieldType, field_tag.OutputWithSpace())
 		case *fproto.MapFieldElement:
 			// fieldname map[keytype]fieldtype
 			g.FMain().GenerateComment(xfld.Comment)
 
 			tinfo, err := g.GetTypeInfoFromParent(tp_msg, xfld.Type)
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
 			tinfokey, err := g.GetTypeInfoFromParent(tp_msg, xfld.KeyType)
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
 
 			g.FMain().P(fldGoName, " map[", tinfokey.Converter().TypeName(g.FMain(), TNT_TYPENAME, 0), "]", tinfo.Converter().TypeName(g.FMain(), TNT_TYPENAME, 0), field_tag.OutputWithSpace())
@@ -333,6 +444,22 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 
 			g.FMain().P(fldGoName, " ", oneofGoName, field_tag.OutputWithSpace())
 		}
+
+		g.FMain().EndElement()
+	}
+
+	// extension values, if the message is extendable
+	if len(message.Extensions) > 0 {
+		util_alias := g.FMain().DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util")
+
+		g.FMain().P("XXX_Extensions ", util_alias, ".Extensions `json:\"-\"`")
+	}
+
+	// unknown fields of the source message
+	if g.KeepUnknownFields {
+		util_alias := g.FMain().DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util")
+
+		g.FMain().P("XXX_unrecognized ", util_alias, ".UnknownFields `json:\"-\"`")
 	}
 
 	g.FMain().Out()
@@ -344,7 +471,7 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 	//
 	g.FImpExp().GenerateCommentLine("IMPORT: ", msgProtoName)
 
-	g.FImpExp().P("func ", msgGoName, "_Import(s *", go_alias_ie, ".", msgGoName, ") (*", msgGoName, ", error) {")
+	g.FImpExp().P("func ", g.wrapName(g.depfile, "", msgGoName+"_Import"), "(s *", go_alias_ie, ".", msgGoName, ") (*", msgGoName, ", error) {")
 	g.FImpExp().In()
 
 	g.FImpExp().P("if s == nil {")
@@ -358,6 +485,8 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 	g.FImpExp().P("ret := &", msgGoName, "{}")
 
 	for _, fld := range message.Fields {
+		g.FImpExp().BeginElement(fld)
+
 		fldGoName, fldProtoName := g.BuildFieldName(fld)
 
 		g.FImpExp().P("// ", msgProtoName, ".", fldProtoName)
@@ -367,9 +496,12 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 			// fieldname = go_package.fieldname
 			tinfo, err := g.GetTypeInfoFromParent(tp_msg, xfld.Type)
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
 
+			optional := g.isPointerValueField(tinfo, xfld)
+			_, tc_presence := tinfo.Converter().(TypeConverter_Presence)
+
 			source_field := "s." + fldGoName
 			dest_field := "ret." + fldGoName
 			if xfld.Repeated {
@@ -379,11 +511,21 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 
 				source_field = "ms"
 				dest_field = "msi"
+			} else if optional {
+				// unset values are kept unset
+				g.FImpExp().P("if s.", fldGoName, " != nil {")
+				g.FImpExp().In()
+
+				source_field = "(*s." + fldGoName + ")"
+				if !tc_presence && !tinfo.Converter().IsPointer() {
+					g.FImpExp().P("var msi ", tinfo.Converter().TypeName(g.FImpExp(), TNT_TYPENAME, 0))
+					dest_field = "msi"
+				}
 			}
 
 			check_error, err := tinfo.Converter().GenerateImport(g.FImpExp(), source_field, dest_field, "err")
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
 			if check_error {
 				g.FImpExp().GenerateErrorCheck("&" + msgGoName + "{}")
@@ -392,6 +534,13 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 			if xfld.Repeated {
 				g.FImpExp().P("ret.", fldGoName, " = append(ret.", fldGoName, ", msi)")
 
+				g.FImpExp().Out()
+				g.FImpExp().P("}")
+			} else if optional {
+				if dest_field == "msi" {
+					g.FImpExp().P("ret.", fldGoName, " = &msi")
+				}
+
 				g.FImpExp().Out()
 				g.FImpExp().P("}")
 			}
@@ -399,11 +548,11 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 			// fieldname map[keytype]fieldtype
 			tinfo, err := g.GetTypeInfoFromParent(tp_msg, xfld.Type)
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
 			tinfokey, err := g.GetTypeInfoFromParent(tp_msg, xfld.KeyType)
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
 
 			g.FImpExp().P("if len(s.", fldGoName, ") > 0 {")
@@ -417,7 +566,7 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 
 			check_error, err := tinfo.Converter().GenerateImport(g.FImpExp(), "ms", "msi", "err")
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
 			if check_error {
 				g.FImpExp().GenerateErrorCheck("&" + msgGoName + "{}")
@@ -441,7 +590,7 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 					g.FImpExp().P("case *", go_alias_ie, ".", oneofFieldGoName, ":")
 					g.FImpExp().In()
 
-					g.FImpExp().P("ret.", fldGoName, ", err = ", oneofFieldGoName, "_Import(en)")
+					g.FImpExp().P("ret.", fldGoName, ", err = ", g.wrapName(g.depfile, "", oneofFieldGoName+"_Import"), "(en)")
 
 					g.FImpExp().Out()
 				}
@@ -451,8 +600,26 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 
 			g.FImpExp().GenerateErrorCheck("&" + msgGoName + "{}")
 		}
+
+		g.FImpExp().EndElement()
+	}
+
+	// extensions
+	if len(message.Extensions) > 0 {
+		util_alias := g.FImpExp().DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util")
+
+		g.FImpExp().P("// ", msgProtoName, " extensions")
+		g.FImpExp().P("ret.XXX_Extensions, err = ", util_alias, ".ImportExtensions(s)")
+		g.FImpExp().GenerateErrorCheck("&" + msgGoName + "{}")
 	}
 
+	// unknown fields
+	if g.KeepUnknownFields {
+		util_alias := g.FImpExp().DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util")
+
+		g.FImpExp().P("// ", msgProtoName, " unknown fields")
+		g.FImpExp().P("ret.XXX_unrecognized = ", util_alias, ".ImportUnknownFields(s.XXX_unrecognized)")
+	}
 	g.FImpExp().P("return ret, err")
 
 	g.FImpExp().Out()
@@ -465,7 +632,7 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 	//
 	g.FImpExp().GenerateCommentLine("EXPORT: ", msgProtoName)
 
-	g.FImpExp().P("func (m *", msgGoName, ") Export() (*", go_alias_ie, ".", msgGoName, ", error) {")
+	g.FImpExp().P("func (m *", msgGoName, ") ", g.wrapName(g.depfile, msgGoName, "Export"), "() (*", go_alias_ie, ".", msgGoName, ", error) {")
 	g.FImpExp().In()
 
 	g.FImpExp().P("if m == nil {")
@@ -479,6 +646,8 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 	g.FImpExp().P("ret := &", go_alias_ie, ".", msgGoName, "{}")
 
 	for _, fld := range message.Fields {
+		g.FImpExp().BeginElement(fld)
+
 		fldGoName, fldProtoName := g.BuildFieldName(fld)
 
 		g.FImpExp().P("// ", msgProtoName, ".", fldProtoName)
@@ -488,23 +657,42 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 			// fieldname = go_package.fieldname
 			tinfo, err := g.GetTypeInfoFromParent(tp_msg, xfld.Type)
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
 
 			source_field := "m." + fldGoName
 			dest_field := "ret." + fldGoName
+			optional := g.isPointerValueField(tinfo, xfld)
 			if xfld.Repeated {
 				g.FImpExp().P("for _, ms := range m.", fldGoName, " {")
 				g.FImpExp().In()
 				g.FImpExp().P("var msi ", tinfo.Source().TypeName(g.FImpExp(), TNT_TYPENAME, 0))
 
 				source_field = "ms"
+				dest_field = "msi"
+			} else if optional {
+				// unset values are kept unset
+				if tcp, ok := tinfo.Converter().(TypeConverter_Presence); ok {
+					has_expr, err := tcp.GeneratePresence(g.FImpExp(), source_field)
+					if err != nil {
+						return g.elementError(fld, err)
+					}
+					g.FImpExp().P("if ", has_expr, " {")
+				} else {
+					g.FImpExp().P("if m.", fldGoName, " != nil {")
+					if !tinfo.Converter().IsPointer() {
+						source_field = "(*m." + fldGoName + ")"
+					}
+				}
+				g.FImpExp().In()
+				g.FImpExp().P("var msi ", tinfo.Source().TypeName(g.FImpExp(), TNT_TYPENAME, 0))
+
 				dest_field = "msi"
 			}
 
 			check_error, err := tinfo.Converter().GenerateExport(g.FImpExp(), source_field, dest_field, "err")
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
 			if check_error {
 				g.FImpExp().GenerateErrorCheck("&" + go_alias_ie + "." + msgGoName + "{}")
@@ -513,6 +701,11 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 			if xfld.Repeated {
 				g.FImpExp().P("ret.", fldGoName, " = append(ret.", fldGoName, ", msi)")
 
+				g.FImpExp().Out()
+				g.FImpExp().P("}")
+			} else if optional {
+				g.FImpExp().P("ret.", fldGoName, " = &msi")
+
 				g.FImpExp().Out()
 				g.FImpExp().P("}")
 			}
@@ -521,12 +714,12 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 			// fieldname map[keytype]fieldtype
 			tinfo, err := g.GetTypeInfoFromParent(tp_msg, xfld.Type)
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
 
 			tinfokey, err := g.GetTypeInfoFromParent(tp_msg, xfld.KeyType)
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
 
 			g.FImpExp().P("if len(m.", fldGoName, ") > 0 {")
@@ -540,7 +733,7 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 
 			check_error, err := tinfo.Converter().GenerateExport(g.FImpExp(), "ms", "msi", "err")
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
 			if check_error {
 				g.FImpExp().GenerateErrorCheck("&" + go_alias_ie + "." + msgGoName + "{}")
@@ -564,7 +757,7 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 					g.FImpExp().P("case *", oneofFieldGoName, ":")
 					g.FImpExp().In()
 
-					g.FImpExp().P("ret.", fldGoName, ", err = ", "en.Export()")
+					g.FImpExp().P("ret.", fldGoName, ", err = ", "en.", g.wrapName(g.depfile, oneofFieldGoName, "Export"), "()")
 
 					g.FImpExp().Out()
 				}
@@ -574,8 +767,22 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 
 			g.FImpExp().GenerateErrorCheck("&" + go_alias_ie + "." + msgGoName + "{}")
 		}
+
+		g.FImpExp().EndElement()
+	}
+
+	// extensions
+	if len(message.Extensions) > 0 {
+		g.FImpExp().P("// ", msgProtoName, " extensions")
+		g.FImpExp().P("err = m.XXX_Extensions.Export(ret)")
+		g.FImpExp().GenerateErrorCheck("&" + go_alias_ie + "." + msgGoName + "{}")
 	}
 
+	// unknown fields
+	if g.KeepUnknownFields {
+		g.FImpExp().P("// ", msgProtoName, " unknown fields")
+		g.FImpExp().P("ret.XXX_unrecognized = m.XXX_unrecognized.Export()")
+	}
 	g.FImpExp().P("return ret, err")
 
 	g.FImpExp().Out()
@@ -583,20 +790,378 @@ func (g *Generator) generateMessage(message *fproto.MessageElement) error {
 
 	g.FImpExp().P()
 
+	// Field presence and defaults
+	err := g.generateFieldHelpers(message, tp_msg)
+	if err != nil {
+		return err
+	}
+
 	// Oneofs
 	for _, fld := range message.Fields {
 		switch xfld := fld.(type) {
 		case *fproto.OneOfFieldElement:
+			g.BeginElement(xfld)
 			err := g.generateOneOf(xfld)
 			if err != nil {
-				return err
+				return g.elementError(fld, err)
 			}
+			g.EndElement()
 		}
 	}
 
 	return nil
 }
 
+// Generates the Has, Clear and Get helpers of the proto2 singular fields and of the proto3 optional fields.
+// Has and Clear are generated when the field Go type can track presence, and Get returns the field value or its
+// proto default for scalar and enum fields.
+func (g *Generator) generateFieldHelpers(message *fproto.MessageElement, tp_msg *fdep.DepType) error {
+	msgGoName, msgProtoName := g.BuildMessageName(message)
+
+	for _, fld := range message.Fields {
+		xfld, ok := fld.(*fproto.FieldElement)
+		if !ok || xfld.Repeated {
+			continue
+		}
+
+		fldGoName, fldProtoName := g.BuildFieldName(xfld)
+		fldVar := "m." + fldGoName
+
+		tp_fld, err := tp_msg.GetType(xfld.Type)
+		if err != nil {
+			return g.elementError(fld, err)
+		}
+		tc, err := g.GetTypeConverter(tp_fld)
+		if err != nil {
+			return g.elementError(fld, err)
+		}
+
+		fieldType, presence, getter, err := g.fieldHelpers(g.FMain(), tp_fld, xfld)
+		if err != nil {
+			return g.elementError(fld, err)
+		}
+		if fieldType == "" {
+			continue
+		}
+
+		//
+		// func (m *MyMessage) HasField() bool
+		// func (m *MyMessage) ClearField()
+		//
+		if presence {
+			var hasExpr string
+			var generateClear func() error
+			if tcp, ok := tc.(TypeConverter_Presence); ok {
+				hasExpr, err = tcp.GeneratePresence(g.FMain(), fldVar)
+				if err != nil {
+					return g.elementError(fld, err)
+				}
+				generateClear = func() error {
+					return tcp.GenerateClear(g.FMain(), fldVar)
+				}
+			} else {
+				hasExpr = fldVar + " != nil"
+				generateClear = func() error {
+					g.FMain().P(fldVar, " = nil")
+					return nil
+				}
+			}
+
+			g.FMain().P("// Returns whether ", msgProtoName, ".", fldProtoName, " is set")
+			g.FMain().P("func (m *", msgGoName, ") ", g.wrapName(g.depfile, msgGoName, "Has"+fldGoName), "() bool {")
+			g.FMain().In()
+			g.FMain().P("return m != nil && ", hasExpr)
+			g.FMain().Out()
+			g.FMain().P("}")
+			g.FMain().P()
+
+			g.FMain().P("// Unsets ", msgProtoName, ".", fldProtoName)
+			g.FMain().P("func (m *", msgGoName, ") ", g.wrapName(g.depfile, msgGoName, "Clear"+fldGoName), "() {")
+			g.FMain().In()
+			g.FMain().P("if m == nil {")
+			g.FMain().In()
+			g.FMain().P("return")
+			g.FMain().Out()
+			g.FMain().P("}")
+			err = generateClear()
+			if err != nil {
+				return g.elementError(fld, err)
+			}
+			g.FMain().Out()
+			g.FMain().P("}")
+			g.FMain().P()
+		}
+
+		//
+		// func (m *MyMessage) GetField() fieldtype
+		//
+		if !getter {
+			continue
+		}
+
+		valueType := tc.TypeName(g.FMain(), TNT_TYPENAME, 0)
+		defaultValue, err := g.fieldDefaultValue(xfld, tp_fld, valueType)
+		if err != nil {
+			return g.elementError(fld, err)
+		}
+
+		g.FMain().P("// Returns the value of ", msgProtoName, ".", fldProtoName, ", or its default value if it is not set")
+		g.FMain().P("func (m *", msgGoName, ") ", g.wrapName(g.depfile, msgGoName, "Get"+fldGoName), "() ", valueType, " {")
+		g.FMain().In()
+		if strings.HasPrefix(fieldType, "*") {
+			g.FMain().P("if m != nil && ", fldVar, " != nil {")
+			g.FMain().In()
+			g.FMain().P("return *", fldVar)
+		} else {
+			g.FMain().P("if m != nil {")
+			g.FMain().In()
+			g.FMain().P("return ", fldVar)
+		}
+		g.FMain().Out()
+		g.FMain().P("}")
+		g.FMain().P("return ", defaultValue)
+		g.FMain().Out()
+		g.FMain().P("}")
+		g.FMain().P()
+	}
+
+	return nil
+}
+
+// Returns which helpers are generated for a singular field: fieldType is the wrapped field type, or blank if the
+// field has no helpers, presence is true for Has and Clear, and getter is true for Get.
+func (g *Generator) fieldHelpers(file *GeneratorFile, tp_fld *fdep.DepType, fld *fproto.FieldElement) (fieldType string, presence bool, getter bool, err error) {
+	if fld.Repeated {
+		return "", false, false, nil
+	}
+
+	tinfo, err := g.GetTypeInfo(tp_fld)
+	if err != nil {
+		return "", false, false, err
+	}
+	tc := tinfo.Converter()
+
+	if g.isProto3Optional(tinfo, fld) {
+		fieldType = g.optionalFieldType(file, tc)
+	} else if g.Syntax() == GeneratorSyntax_Proto2 {
+		fieldType = tc.TypeName(file, TNT_FIELD_DEFINITION, 0)
+	} else {
+		return "", false, false, nil
+	}
+
+	if _, ok := tc.(TypeConverter_Presence); ok {
+		presence = true
+	} else {
+		presence = strings.HasPrefix(fieldType, "*") || strings.HasPrefix(fieldType, "[]") || strings.HasPrefix(fieldType, "map[")
+	}
+
+	switch tc.(type) {
+	case *TypeConverter_Scalar:
+		getter = true
+	case *TypeConverter_Default:
+		_, getter = tp_fld.Item.(*fproto.EnumElement)
+	}
+
+	return fieldType, presence, getter, nil
+}
+
+// Returns whether the field is a proto3 optional field, which tracks presence.
+// Message fields are always pointers, so they are not considered.
+func (g *Generator) isProto3Optional(tinfo TypeInfo, fld *fproto.FieldElement) bool {
+	return g.Syntax() == GeneratorSyntax_Proto3 && fld.Optional && !fld.Repeated && !tinfo.Source().IsPointer()
+}
+
+// Returns whether the source field is a pointer to a value that must be converted by value, keeping nil values unset:
+// proto3 optional fields, and proto2 singular fields with converters implementing TypeConverter_Value.
+func (g *Generator) isPointerValueField(tinfo TypeInfo, fld *fproto.FieldElement) bool {
+	if g.isProto3Optional(tinfo, fld) {
+		return true
+	}
+	if fld.Repeated || g.Syntax() != GeneratorSyntax_Proto2 || !isValueOnly(tinfo.Converter()) {
+		return false
+	}
+	return !tinfo.Source().IsPointer() && strings.HasPrefix(tinfo.Source().TypeName(g.FImpExp(), TNT_FIELD_DEFINITION, 0), "*")
+}
+
+func isValueOnly(tc TypeConverter) bool {
+	tcv, ok := tc.(TypeConverter_Value)
+	return ok && tcv.ValueOnly()
+}
+
+// Returns the wrapped type of a proto3 optional field.
+// Converters implementing TypeConverter_Presence use their own type, all others are used as pointers.
+func (g *Generator) optionalFieldType(file *GeneratorFile, tc TypeConverter) string {
+	if _, ok := tc.(TypeConverter_Presence); ok || tc.IsPointer() {
+		return tc.TypeName(file, TNT_TYPENAME, 0)
+	}
+	return "*" + tc.TypeName(file, TNT_TYPENAME, 0)
+}
+
+// Returns the Go expression of the default value of a scalar or enum field, from its "default" option.
+func (g *Generator) fieldDefaultValue(fld *fproto.FieldElement, tp *fdep.DepType, goType string) (string, error) {
+	var defval string
+	hasDefault := false
+	for _, o := range fld.Options {
+		if o.Name == "default" {
+			defval = o.Value.String()
+			hasDefault = true
+		}
+	}
+
+	if enum, ok := tp.Item.(*fproto.EnumElement); ok {
+		// the default of an enum is its first value
+		if len(enum.EnumConstants) == 0 {
+			return "", fmt.Errorf("enum '%s' has no values", enum.Name)
+		}
+		if !hasDefault {
+			return fmt.Sprintf("%s(%d)", goType, enum.EnumConstants[0].Tag), nil
+		}
+		for _, ec := range enum.EnumConstants {
+			if ec.Name == defval {
+				return fmt.Sprintf("%s(%d)", goType, ec.Tag), nil
+			}
+		}
+		return "", fmt.Errorf("default value '%s' is not a value of enum '%s'", defval, enum.Name)
+	}
+
+	if !tp.IsScalar() {
+		return "", fmt.Errorf("type '%s' has no default value", tp.Name)
+	}
+
+	switch *tp.ScalarType {
+	case fproto.StringScalar, fproto.BytesScalar:
+		if *tp.ScalarType == fproto.BytesScalar && !hasDefault {
+			return "nil", nil
+		}
+		// the option keeps the proto escapes of the source
+		value, err := unescapeProtoString(defval)
+		if err != nil {
+			return "", fmt.Errorf("invalid default value '%s': %v", defval, err)
+		}
+		if *tp.ScalarType == fproto.BytesScalar {
+			return "[]byte(" + strconv.Quote(value) + ")", nil
+		}
+		return strconv.Quote(value), nil
+	case fproto.BoolScalar:
+		if !hasDefault {
+			return "false", nil
+		}
+		if defval != "true" && defval != "false" {
+			return "", fmt.Errorf("invalid bool default value '%s'", defval)
+		}
+		return defval, nil
+	case fproto.DoubleScalar, fproto.FloatScalar:
+		if !hasDefault {
+			return "0", nil
+		}
+		switch defval {
+		case "inf":
+			return goType + "(" + g.FMain().DeclDep("math", "math") + ".Inf(1))", nil
+		case "-inf":
+			return goType + "(" + g.FMain().DeclDep("math", "math") + ".Inf(-1))", nil
+		case "nan":
+			return goType + "(" + g.FMain().DeclDep("math", "math") + ".NaN())", nil
+		}
+		if _, err := strconv.ParseFloat(defval, 64); err != nil {
+			return "", fmt.Errorf("invalid float default value '%s'", defval)
+		}
+		return goType + "(" + defval + ")", nil
+	}
+
+	// integers
+	if !hasDefault {
+		return "0", nil
+	}
+	if _, err := strconv.ParseInt(defval, 0, 64); err != nil {
+		if _, err := strconv.ParseUint(defval, 0, 64); err != nil {
+			return "", fmt.Errorf("invalid integer default value '%s'", defval)
+		}
+	}
+	return goType + "(" + defval + ")", nil
+}
+
+// Decodes the escapes of a proto string literal: the C escapes, octal (\ooo), hex (\xHH) and unicode (\uHHHH,
+// \UHHHHHHHH) ones.
+func unescapeProtoString(value string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+
+		i++
+		if i >= len(value) {
+			return "", errors.New("escape at end of string")
+		}
+		c = value[i]
+		switch c {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '\\', '\'', '"', '?':
+			b.WriteByte(c)
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// up to 3 octal digits
+			n := 0
+			j := i
+			for ; j < len(value) && j < i+3 && value[j] >= '0' && value[j] <= '7'; j++ {
+				n = n*8 + int(value[j]-'0')
+			}
+			if n > 0xff {
+				return "", fmt.Errorf("octal escape out of range: \\%s", value[i:j])
+			}
+			b.WriteByte(byte(n))
+			i = j - 1
+		case 'x', 'X':
+			// up to 2 hex digits
+			j := i + 1
+			for ; j < len(value) && j < i+3 && isHexDigit(value[j]); j++ {
+			}
+			if j == i+1 {
+				return "", errors.New("hex escape without digits")
+			}
+			n, _ := strconv.ParseUint(value[i+1:j], 16, 8)
+			b.WriteByte(byte(n))
+			i = j - 1
+		case 'u', 'U':
+			size := 4
+			if c == 'U' {
+				size = 8
+			}
+			if i+size >= len(value) {
+				return "", fmt.Errorf("incomplete unicode escape: \\%s", value[i:])
+			}
+			n, err := strconv.ParseUint(value[i+1:i+1+size], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(n)) {
+				return "", fmt.Errorf("invalid unicode escape: \\%s", value[i:i+1+size])
+			}
+			b.WriteRune(rune(n))
+			i += size
+		default:
+			return "", fmt.Errorf("unknown escape: \\%c", c)
+		}
+	}
+	return b.String(), nil
+}
+
+// Is c an ASCII hex digit?
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
 func (g *Generator) BuildEnumName(enum *fproto.EnumElement) (goName string, protoName string) {
 	// get the dep type
 	tp_enum := g.dep.DepTypeFromElement(enum)
@@ -634,6 +1199,10 @@ func (g *Generator) BuildEnumConstantName(ec *fproto.EnumConstantElement) (goNam
 }
 
 func (g *Generator) generateEnum(enum *fproto.EnumElement) error {
+	if g.EnumWrap {
+		return g.generateEnumWrap(enum)
+	}
+
 	enGoName, enProtoName := g.BuildEnumName(enum)
 
 	// build aliases to the original type
@@ -675,6 +1244,140 @@ func (g *Generator) generateEnum(enum *fproto.EnumElement) error {
 	return nil
 }
 
+// Generates the enum as a new type, with conversion and validation helpers.
+func (g *Generator) generateEnumWrap(enum *fproto.EnumElement) error {
+	enGoName, enProtoName := g.BuildEnumName(enum)
+
+	go_alias := g.FMain().DeclFileDep(nil, "", false)
+	fmt_alias := g.FMain().DeclDep("fmt", "fmt")
+	strconv_alias := g.FMain().DeclDep("strconv", "strconv")
+
+	//
+	// type MyEnum int32
+	//
+	if !g.FMain().GenerateComment(enum.Comment) {
+		g.FMain().GenerateCommentLine("ENUM: ", enProtoName)
+	}
+
+	g.FMain().P("type ", enGoName, " int32")
+	g.FMain().P()
+	g.FMain().P("const (")
+	g.FMain().In()
+
+	for _, ec := range enum.EnumConstants {
+		// MyEnumConstant = MyEnum(go_package.MyEnumConstant)
+		ecGoName, _ := g.BuildEnumConstantName(ec)
+
+		g.FMain().GenerateComment(ec.Comment)
+
+		g.FMain().P(ecGoName, " = ", enGoName, "(", go_alias, ".", ecGoName, ")")
+	}
+
+	g.FMain().Out()
+	g.FMain().P(")")
+	g.FMain().P()
+
+	// var MyEnum_name = go_package.MyEnum_name
+	g.FMain().P("var ", enGoName, "_name = ", go_alias, ".", enGoName, "_name")
+
+	// var MyEnum_value = go_package.MyEnum_value
+	g.FMain().P("var ", enGoName, "_value = ", go_alias, ".", enGoName, "_value")
+
+	g.FMain().P()
+
+	//
+	// func (x MyEnum) String() string
+	//
+	g.FMain().P("// Returns the name of the value, or its number if it is not a valid value")
+	g.FMain().P("func (x ", enGoName, ") String() string {")
+	g.FMain().In()
+	g.FMain().P("if s, ok := ", enGoName, "_name[int32(x)]; ok {")
+	g.FMain().In()
+	g.FMain().P("return s")
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().P("return ", strconv_alias, ".Itoa(int(x))")
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().P()
+
+	//
+	// func (x MyEnum) IsValid() bool
+	//
+	g.FMain().P("// Returns whether the value is declared on the enum")
+	g.FMain().P("func (x ", enGoName, ") IsValid() bool {")
+	g.FMain().In()
+	g.FMain().P("_, ok := ", enGoName, "_name[int32(x)]")
+	g.FMain().P("return ok")
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().P()
+
+	//
+	// func ParseMyEnum(s string) (MyEnum, error)
+	//
+	g.FMain().P("// Returns the value with the name")
+	parseName := g.wrapName(g.depfile, "", "Parse"+enGoName)
+	g.FMain().P("func ", parseName, "(s string) (", enGoName, ", error) {")
+	g.FMain().In()
+	g.FMain().P("if v, ok := ", enGoName, "_value[s]; ok {")
+	g.FMain().In()
+	g.FMain().P("return ", enGoName, "(v), nil")
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().P("return 0, ", fmt_alias, ".Errorf(\"Invalid value for enum ", enProtoName, ": %q\", s)")
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().P()
+
+	//
+	// func MyEnum_Values() []MyEnum
+	//
+	g.FMain().P("// Returns the values of the enum, in declaration order")
+	g.FMain().P("func ", g.wrapName(g.depfile, "", enGoName+"_Values"), "() []", enGoName, " {")
+	g.FMain().In()
+	g.FMain().P("return []", enGoName, "{")
+	g.FMain().In()
+	for _, ec := range enum.EnumConstants {
+		ecGoName, _ := g.BuildEnumConstantName(ec)
+		g.FMain().P(ecGoName, ",")
+	}
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().P()
+
+	//
+	// func (x MyEnum) MarshalText() ([]byte, error)
+	// func (x *MyEnum) UnmarshalText(text []byte) error
+	//
+	g.FMain().P("// Marshals the value as its name")
+	g.FMain().P("func (x ", enGoName, ") MarshalText() ([]byte, error) {")
+	g.FMain().In()
+	g.FMain().P("return []byte(x.String()), nil")
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().P()
+
+	g.FMain().P("// Unmarshals the value from its name")
+	g.FMain().P("func (x *", enGoName, ") UnmarshalText(text []byte) error {")
+	g.FMain().In()
+	g.FMain().P("v, err := ", parseName, "(string(text))")
+	g.FMain().P("if err != nil {")
+	g.FMain().In()
+	g.FMain().P("return err")
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().P("*x = v")
+	g.FMain().P("return nil")
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().P()
+
+	return nil
+}
+
 func (g *Generator) BuildOneOfName(oneof *fproto.OneOfFieldElement) (goName string, protoName string) {
 	// get the dep type
 	tp_oneof := g.dep.DepTypeFromElement(oneof)
@@ -706,11 +1409,37 @@ func (g *Generator) BuildOneOfFieldName(oneoffield fproto.FieldElementTag) (goNa
 	// the Go name uses the message as the scope
 	goName = fproto_wrap.CamelCaseProtoElement(tp_msg.Name) + "_" + fproto_wrap.CamelCase(oneoffield.FieldName())
 
+	// suffixed with "_" if it collides with a message or enum, like protoc-gen-go does
+	goName = g.wrapName(tp_fld.DepFile, "", goName)
+
 	protoName = tp_fld.Name
 
 	return
 }
 
+// Checks if all oneof members can be wrapped. Protobuf only allows singular fields inside oneofs, so
+// protoc-gen-go never generates any other member kind, and there would be no source type to convert from.
+func (g *Generator) checkOneOfFields(tp_msg *fdep.DepType, oneof *fproto.OneOfFieldElement) error {
+	for _, oofld := range oneof.Fields {
+		var reason string
+		switch xoofld := oofld.(type) {
+		case *fproto.FieldElement:
+			if xoofld.Repeated {
+				reason = "repeated fields are not supported inside oneofs"
+			}
+		case *fproto.MapFieldElement:
+			reason = "map fields are not supported inside oneofs"
+		default:
+			reason = fmt.Sprintf("%s elements are not supported inside oneofs", oofld.ElementTypeName())
+		}
+
+		if reason != "" {
+			return g.elementError(oofld, errors.New(reason))
+		}
+	}
+	return nil
+}
+
 func (g *Generator) generateOneOf(oneof *fproto.OneOfFieldElement) error {
 	// CUSTOMIZER
 	cz := &wrapCustomizers{g.Customizers}
@@ -718,7 +1447,7 @@ func (g *Generator) generateOneOf(oneof *fproto.OneOfFieldElement) error {
 	// get DepType from element
 	tp_oneof := g.dep.DepTypeFromElement(oneof)
 	if tp_oneof == nil {
-		return errors.New("oneof type not found")
+		return g.elementError(oneof, errors.New("oneof type not found"))
 	}
 
 	// build aliases to the original type
@@ -747,7 +1476,7 @@ func (g *Generator) generateOneOf(oneof *fproto.OneOfFieldElement) error {
 
 		err := cz.GetTag(g, field_tag, oneof, oofld)
 		if err != nil {
-			return err
+			return g.elementError(oofld, err)
 		}
 
 		fldGoName, _ := g.BuildFieldName(oofld)
@@ -759,7 +1488,7 @@ func (g *Generator) generateOneOf(oneof *fproto.OneOfFieldElement) error {
 			// }
 			tinfo, err := g.GetTypeInfoFromParent(tp_oneof, xoofld.Type)
 			if err != nil {
-				return err
+				return g.elementError(oofld, err)
 			}
 
 			oneofFieldGoName, oneofFieldProtoName := g.BuildOneOfFieldName(xoofld)
@@ -784,7 +1513,7 @@ func (g *Generator) generateOneOf(oneof *fproto.OneOfFieldElement) error {
 			//
 			g.FImpExp().GenerateCommentLine("IMPORT: ", oneofFieldProtoName)
 
-			g.FImpExp().P("func ", oneofFieldGoName, "_Import(s *", go_alias_ie, ".", oneofFieldGoName, ") (*", oneofFieldGoName, ", error) {")
+			g.FImpExp().P("func ", g.wrapName(g.depfile, "", oneofFieldGoName+"_Import"), "(s *", go_alias_ie, ".", oneofFieldGoName, ") (*", oneofFieldGoName, ", error) {")
 			g.FImpExp().In()
 
 			g.FImpExp().P("var err error")
@@ -792,7 +1521,7 @@ func (g *Generator) generateOneOf(oneof *fproto.OneOfFieldElement) error {
 
 			check_error, err := tinfo.Converter().GenerateImport(g.FImpExp(), "s."+fldGoName, "ret."+fldGoName, "err")
 			if err != nil {
-				return err
+				return g.elementError(oofld, err)
 			}
 			if check_error {
 				g.FImpExp().GenerateErrorCheck("nil")
@@ -808,7 +1537,7 @@ func (g *Generator) generateOneOf(oneof *fproto.OneOfFieldElement) error {
 			//
 			g.FImpExp().GenerateCommentLine("EXPORT: ", oneofFieldProtoName)
 
-			g.FImpExp().P("func (o *", oneofFieldGoName, ") Export() (*", go_alias_ie, ".", oneofFieldGoName, ", error) {")
+			g.FImpExp().P("func (o *", oneofFieldGoName, ") ", g.wrapName(g.depfile, oneofFieldGoName, "Export"), "() (*", go_alias_ie, ".", oneofFieldGoName, ", error) {")
 			g.FImpExp().In()
 
 			g.FImpExp().P("var err error")
@@ -816,7 +1545,7 @@ func (g *Generator) generateOneOf(oneof *fproto.OneOfFieldElement) error {
 
 			check_error, err = tinfo.Converter().GenerateExport(g.FImpExp(), "o."+fldGoName, "ret."+fldGoName, "err")
 			if err != nil {
-				return err
+				return g.elementError(oofld, err)
 			}
 			if check_error {
 				g.FImpExp().GenerateErrorCheck("nil")
@@ -832,15 +1561,302 @@ func (g *Generator) generateOneOf(oneof *fproto.OneOfFieldElement) error {
 	return nil
 }
 
-// Get type converter for type
-func (g *Generator) findTypeConv(tp *fdep.DepType) TypeConverter {
+// Returns the message extended by an extend block, a function to resolve types in the scope of the block, and the
+// prefix of the accessor names ("Msg_" when the block is declared inside a message).
+func (g *Generator) extendTarget(extend *fproto.MessageElement) (tp_extendee *fdep.DepType, getType func(name string) (*fdep.DepType, error), scopeGoName string, err error) {
+	// the extend scope, for type resolution and naming
+	var tp_scope *fdep.DepType
+	if parent_msg, ok := extend.Parent.(*fproto.MessageElement); ok {
+		tp_scope = g.dep.DepTypeFromElement(parent_msg)
+		if tp_scope == nil {
+			return nil, nil, "", g.elementError(extend, errors.New("extend parent message type not found"))
+		}
+		scopeGoName, _ = g.BuildMessageName(parent_msg)
+		scopeGoName += "_"
+	}
+
+	getType = func(name string) (*fdep.DepType, error) {
+		if tp_scope != nil {
+			return tp_scope.GetType(name)
+		}
+		return g.depfile.GetType(name)
+	}
+
+	// the extended message
+	tp_extendee, err = getType(extend.Name)
+	if err != nil {
+		return nil, nil, "", g.elementError(extend, err)
+	}
+	if _, ok := tp_extendee.Item.(*fproto.MessageElement); !ok {
+		return nil, nil, "", g.elementError(extend, fmt.Errorf("extended type %s is not a message", extend.Name))
+	}
+
+	return tp_extendee, getType, scopeGoName, nil
+}
+
+// Generates typed accessors for the extension fields of an extend block.
+// Extensions of messages that are not wrapped (like custom options) are skipped.
+func (g *Generator) generateExtend(extend *fproto.MessageElement) error {
+	tp_extendee, getType, scopeGoName, err := g.extendTarget(extend)
+	if err != nil {
+		return err
+	}
+	if !g.IsFileWrap(tp_extendee.DepFile) {
+		return nil
+	}
+	extendee := tp_extendee.Item.(*fproto.MessageElement)
+
+	extendeeGoName, extendeeProtoName := g.BuildMessageName(extendee)
+	if !tp_extendee.DepFile.IsSamePackage(g.depfile) {
+		extendeeGoName = g.FImpExp().DeclFileDep(tp_extendee.DepFile, tp_extendee.Alias, true) + "." + extendeeGoName
+	}
+
+	for _, fld := range extend.Fields {
+		g.BeginElement(fld)
+
+		xfld, ok := fld.(*fproto.FieldElement)
+		if !ok {
+			return g.elementError(fld, errors.New("only fields are supported in extend blocks"))
+		}
+
+		fldGoName, fldProtoName := g.BuildFieldName(xfld)
+		extGoName := scopeGoName + fldGoName
+		getExtName := g.wrapName(g.depfile, "", "GetExtension_"+extGoName)
+		setExtName := g.wrapName(g.depfile, "", "SetExtension_"+extGoName)
+		clearExtName := g.wrapName(g.depfile, "", "ClearExtension_"+extGoName)
+
+		tp_fld, err := getType(xfld.Type)
+		if err != nil {
+			return g.elementError(fld, err)
+		}
+		tinfo, err := g.GetTypeInfo(tp_fld)
+		if err != nil {
+			return g.elementError(fld, err)
+		}
+
+		// the source type is the one returned by proto.GetExtension
+		var type_prefix string
+		tctn := TNT_FIELD_DEFINITION
+		if xfld.Repeated {
+			type_prefix = "[]"
+			tctn = TNT_TYPENAME
+		}
+		wrapType := type_prefix + tinfo.Converter().TypeName(g.FImpExp(), tctn, 0)
+		sourceType := type_prefix + tinfo.Source().TypeName(g.FImpExp(), tctn, 0)
+
+		fmt_alias := g.FImpExp().DeclDep("fmt", "fmt")
+		util_alias := g.FImpExp().DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util")
+
+		//
+		// func GetExtension_Field(m *MyMessage) (fieldtype, bool, error)
+		//
+		if !g.FImpExp().GenerateComment(xfld.Comment) {
+			g.FImpExp().GenerateCommentLine("EXTENSION: ", extendeeProtoName, ".", fldProtoName)
+		}
+
+		g.FImpExp().P("// Returns the value of the extension ", fldProtoName, ", and false if it is not set")
+		g.FImpExp().P("func ", getExtName, "(m *", extendeeGoName, ") (", wrapType, ", bool, error) {")
+		g.FImpExp().In()
+
+		g.FImpExp().P("var ret ", wrapType)
+		g.FImpExp().P("if m == nil {")
+		g.FImpExp().In()
+		g.FImpExp().P("return ret, false, nil")
+		g.FImpExp().Out()
+		g.FImpExp().P("}")
+		g.FImpExp().P("v, ok := m.XXX_Extensions[", xfld.Tag, "]")
+		g.FImpExp().P("if !ok {")
+		g.FImpExp().In()
+		g.FImpExp().P("return ret, false, nil")
+		g.FImpExp().Out()
+		g.FImpExp().P("}")
+		g.FImpExp().P("s, ok := v.(", sourceType, ")")
+		g.FImpExp().P("if !ok {")
+		g.FImpExp().In()
+		g.FImpExp().P("return ret, false, ", fmt_alias, ".Errorf(\"Invalid type %T for extension ", extendeeProtoName, ".", fldProtoName, "\", v)")
+		g.FImpExp().Out()
+		g.FImpExp().P("}")
+		g.FImpExp().P()
+		g.FImpExp().P("var err error")
+
+		source_field := "s"
+		dest_field := "ret"
+		by_value := g.isPointerValueField(tinfo, xfld)
+		if xfld.Repeated {
+			g.FImpExp().P("for _, ms := range s {")
+			g.FImpExp().In()
+			g.FImpExp().P("var msi ", tinfo.Converter().TypeName(g.FImpExp(), TNT_TYPENAME, 0))
+
+			source_field = "ms"
+			dest_field = "msi"
+		} else if by_value {
+			g.FImpExp().P("if s != nil {")
+			g.FImpExp().In()
+			g.FImpExp().P("var msi ", tinfo.Converter().TypeName(g.FImpExp(), TNT_TYPENAME, 0))
+
+			source_field = "(*s)"
+			dest_field = "msi"
+		}
+
+		check_error, err := tinfo.Converter().GenerateImport(g.FImpExp(), source_field, dest_field, "err")
+		if err != nil {
+			return g.elementError(fld, err)
+		}
+		if check_error {
+			g.FImpExp().GenerateErrorCheck("ret, false")
+		}
+
+		if xfld.Repeated {
+			g.FImpExp().P("ret = append(ret, msi)")
+
+			g.FImpExp().Out()
+			g.FImpExp().P("}")
+		} else if by_value {
+			g.FImpExp().P("ret = &msi")
+
+			g.FImpExp().Out()
+			g.FImpExp().P("}")
+		}
+
+		g.FImpExp().P("return ret, true, err")
+		g.FImpExp().Out()
+		g.FImpExp().P("}")
+		g.FImpExp().P()
+
+		//
+		// func SetExtension_Field(m *MyMessage, v fieldtype) error
+		//
+		g.FImpExp().P("// Sets the value of the extension ", fldProtoName)
+		g.FImpExp().P("func ", setExtName, "(m *", extendeeGoName, ", v ", wrapType, ") error {")
+		g.FImpExp().In()
+
+		g.FImpExp().P("var s ", sourceType)
+		g.FImpExp().P("var err error")
+
+		source_field = "v"
+		dest_field = "s"
+		if xfld.Repeated {
+			g.FImpExp().P("for _, ms := range v {")
+			g.FImpExp().In()
+			g.FImpExp().P("var msi ", tinfo.Source().TypeName(g.FImpExp(), TNT_TYPENAME, 0))
+
+			source_field = "ms"
+			dest_field = "msi"
+		} else if by_value {
+			g.FImpExp().P("if v != nil {")
+			g.FImpExp().In()
+			g.FImpExp().P("var msi ", tinfo.Source().TypeName(g.FImpExp(), TNT_TYPENAME, 0))
+
+			source_field = "(*v)"
+			dest_field = "msi"
+		}
+
+		check_error, err = tinfo.Converter().GenerateExport(g.FImpExp(), source_field, dest_field, "err")
+		if err != nil {
+			return g.elementError(fld, err)
+		}
+		if check_error {
+			g.FImpExp().GenerateErrorCheck("")
+		}
+
+		if xfld.Repeated {
+			g.FImpExp().P("s = append(s, msi)")
+
+			g.FImpExp().Out()
+			g.FImpExp().P("}")
+		} else if by_value {
+			g.FImpExp().P("s = &msi")
+
+			g.FImpExp().Out()
+			g.FImpExp().P("}")
+		}
+
+		g.FImpExp().P()
+		g.FImpExp().P("if m.XXX_Extensions == nil {")
+		g.FImpExp().In()
+		g.FImpExp().P("m.XXX_Extensions = make(", util_alias, ".Extensions)")
+		g.FImpExp().Out()
+		g.FImpExp().P("}")
+		g.FImpExp().P("m.XXX_Extensions[", xfld.Tag, "] = s")
+		g.FImpExp().P("return err")
+		g.FImpExp().Out()
+		g.FImpExp().P("}")
+		g.FImpExp().P()
+
+		//
+		// func ClearExtension_Field(m *MyMessage)
+		//
+		g.FImpExp().P("// Removes the extension ", fldProtoName)
+		g.FImpExp().P("func ", clearExtName, "(m *", extendeeGoName, ") {")
+		g.FImpExp().In()
+		g.FImpExp().P("if m == nil {")
+		g.FImpExp().In()
+		g.FImpExp().P("return")
+		g.FImpExp().Out()
+		g.FImpExp().P("}")
+		g.FImpExp().P("delete(m.XXX_Extensions, ", xfld.Tag, ")")
+		g.FImpExp().Out()
+		g.FImpExp().P("}")
+		g.FImpExp().P()
+
+		g.EndElement()
+	}
+
+	return nil
+}
+
+// Get type converter for type, chained with the converters of its intermediate types.
+// On a chain cycle, the chain up to the cycle is returned with the error.
+func (g *Generator) findTypeConv(tp *fdep.DepType) (TypeConverter, error) {
+	var tc TypeConverter
 	for _, tcp := range g.TypeConverters {
-		tc := tcp.GetTypeConverter(tp)
+		tc = tcp.GetTypeConverter(tp)
 		if tc != nil {
-			return tc
+			break
 		}
 	}
-	return nil
+	if tc == nil {
+		return nil, nil
+	}
+
+	stages := []TypeConverter{tc}
+	intermediates := []string{tp.FullOriginalName()}
+	var err error
+	for {
+		tci, ok := stages[len(stages)-1].(TypeConverter_Intermediate)
+		if !ok || tci.IntermediateType() == "" {
+			break
+		}
+		itype := tci.IntermediateType()
+
+		cycle := false
+		for _, it := range intermediates {
+			if it == itype {
+				cycle = true
+			}
+		}
+		intermediates = append(intermediates, itype)
+		if cycle {
+			err = fmt.Errorf("Type converter chain cycle: %s", strings.Join(intermediates, " -> "))
+			break
+		}
+
+		var next TypeConverter
+		for _, tcp := range g.TypeConverters {
+			if tcp_chain, ok := tcp.(TypeConverterPlugin_Chain); ok {
+				next = tcp_chain.GetChainTypeConverter(itype, tp)
+				if next != nil {
+					break
+				}
+			}
+		}
+		if next == nil {
+			break
+		}
+		stages = append(stages, next)
+	}
+
+	return NewTypeConverter_Chain(stages), err
 }
 
 func (g *Generator) BuildTypeName(dt *fdep.DepType) (goName string, protoName string) {
@@ -884,25 +1900,29 @@ func (g *Generator) GetTypeSource(tp *fdep.DepType) TypeNamer {
 	}
 }
 
-// Gets the type for the gowrap converter
-func (g *Generator) GetTypeConverter(tp *fdep.DepType) TypeConverter {
+// Gets the type for the gowrap converter. Returns an error if the converter chain of the type is invalid.
+func (g *Generator) GetTypeConverter(tp *fdep.DepType) (TypeConverter, error) {
 	if tp.IsScalar() {
-		return &TypeConverter_Scalar{tp: tp}
+		return &TypeConverter_Scalar{tp: tp}, nil
 	} else {
-		if tc := g.findTypeConv(tp); tc != nil {
-			return tc
+		if tc, err := g.findTypeConv(tp); tc != nil {
+			return tc, err
 		} else {
-			return &TypeConverter_Default{g: g, tp: tp, depfile: g.depfile}
+			return &TypeConverter_Default{g: g, tp: tp, depfile: g.depfile}, nil
 		}
 	}
 }
 
-// Get both source and converter types.
-func (g *Generator) GetTypeInfo(tp *fdep.DepType) TypeInfo {
+// Get both source and converter types. Returns an error if the converter chain of the type is invalid.
+func (g *Generator) GetTypeInfo(tp *fdep.DepType) (TypeInfo, error) {
+	tc, err := g.GetTypeConverter(tp)
+	if err != nil {
+		return nil, err
+	}
 	return &TypeInfo_Default{
 		source:    g.GetTypeSource(tp),
-		converter: g.GetTypeConverter(tp),
-	}
+		converter: tc,
+	}, nil
 }
 
 // Get both source and converter types from a parent and a type name.
@@ -911,7 +1931,7 @@ func (g *Generator) GetTypeInfoFromParent(parent_tp *fdep.DepType, atype string)
 	if err != nil {
 		return nil, err
 	}
-	return g.GetTypeInfo(tp), nil
+	return g.GetTypeInfo(tp)
 }
 
 // Returns the source package name.
@@ -926,6 +1946,10 @@ func (g *Generator) GoPackage(depfile *fdep.DepFile) string {
 
 // Returns the wrapped package name.
 func (g *Generator) GoWrapPackage(depfile *fdep.DepFile) string {
+	if p, ok := g.ExternalWrap[depfile.FilePath]; ok {
+		return p
+	}
+
 	if g.PkgSource != nil {
 		if p, ok := g.PkgSource.GetPkg(g, depfile); ok {
 			return p
@@ -17,6 +17,9 @@ import (
 	"github.com/RangelReale/fproto"
 )
 
+// Marker present on the header of all generated files
+const GENERATED_MARKER = "Code generated by fproto-gowrap"
+
 // A single generated output file
 type GeneratorFile struct {
 	generator        *Generator
@@ -25,34 +28,48 @@ type GeneratorFile struct {
 	FixedFilename    string
 	FixedPackageName string
 
+	// Builds a side-car source map of the regions generated from each proto element, see SourceMap
+	SourceMap bool
+	// Adds a comment with the proto file position before the code of each message, enum, service and extension
+	PositionComments bool
+
 	*bytes.Buffer
 	indent string
 
-	imports  map[string]string
-	havedata bool
+	imports   map[string]string
+	havedata  bool
+	formatted []byte
+
+	elements     []*sourceMapElement
+	openElements []int
+	sourceMap    *SourceMap
 }
 
 // Creates a new generator file
 func NewGeneratorFile(generator *Generator, fileId string, suffix string) *GeneratorFile {
 	return &GeneratorFile{
-		generator: generator,
-		FileId:    fileId,
-		Suffix:    suffix,
-		Buffer:    new(bytes.Buffer),
-		imports:   make(map[string]string),
-		havedata:  false,
+		generator:        generator,
+		FileId:           fileId,
+		Suffix:           suffix,
+		SourceMap:        generator.SourceMap,
+		PositionComments: generator.PositionComments,
+		Buffer:           new(bytes.Buffer),
+		imports:          make(map[string]string),
+		havedata:         false,
 	}
 }
 
 // Creates a new generator file with fixed filename
 func NewGeneratorFileFixed(generator *Generator, fileId string, filename string) *GeneratorFile {
 	return &GeneratorFile{
-		generator:     generator,
-		FileId:        fileId,
-		FixedFilename: filename,
-		Buffer:        new(bytes.Buffer),
-		imports:       make(map[string]string),
-		havedata:      false,
+		generator:        generator,
+		FileId:           fileId,
+		FixedFilename:    filename,
+		SourceMap:        generator.SourceMap,
+		PositionComments: generator.PositionComments,
+		Buffer:           new(bytes.Buffer),
+		imports:          make(map[string]string),
+		havedata:         false,
 	}
 }
 
@@ -138,8 +155,24 @@ func (g *GeneratorFile) DeclDep(imp string, defalias string) string {
 	return alias
 }
 
-// Returns the generated file as a string.
+// Writes the formatted generated file.
 func (g *GeneratorFile) Output(w io.Writer) error {
+	b, err := g.Format()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// Returns the formatted generated file, with header and imports.
+// The result is cached, so no more data must be written on the file after this is called.
+func (g *GeneratorFile) Format() ([]byte, error) {
+	if g.formatted != nil {
+		return g.formatted, nil
+	}
+
 	// write in temporary buffer
 	tmp := new(bytes.Buffer)
 
@@ -151,17 +184,18 @@ func (g *GeneratorFile) Output(w io.Writer) error {
 	g.generateImports()
 
 	// write headers / imports
+	bodyOffset := g.Len()
 	_, err := tmp.Write(g.Bytes())
 	if err != nil {
 		g.Buffer = rem
-		return err
+		return nil, err
 	}
 
 	// write previous content
 	_, err = tmp.Write(rem.Bytes())
 	if err != nil {
 		g.Buffer = rem
-		return err
+		return nil, err
 	}
 
 	// restore buffer
@@ -180,16 +214,22 @@ func (g *GeneratorFile) Output(w io.Writer) error {
 		for line := 1; s.Scan(); line++ {
 			fmt.Fprintf(&src, "%5d\t%s\n", line, s.Bytes())
 		}
-		return errors.New(fmt.Sprint("bad Go source code was generated:", err.Error(), "\n"+src.String()))
+		return nil, g.generator.fileError(errors.New(fmt.Sprint("bad Go source code was generated for ", g.Filename(), ":", err.Error(), "\n"+src.String())))
+	}
+
+	var out bytes.Buffer
+	err = (&printer.Config{Mode: printer.TabIndent | printer.UseSpaces, Tabwidth: 8}).Fprint(&out, fset, ast)
+	if err != nil {
+		return nil, g.generator.fileError(fmt.Errorf("generated Go source code for %s could not be reformatted: %v", g.Filename(), err))
 	}
 
-	// write into the requested io.Writer
-	err = (&printer.Config{Mode: printer.TabIndent | printer.UseSpaces, Tabwidth: 8}).Fprint(w, fset, ast)
+	formatted, err := g.applySourceMap(raw, bodyOffset, out.Bytes())
 	if err != nil {
-		return fmt.Errorf("generated Go source code could not be reformatted:", err.Error())
+		return nil, err
 	}
 
-	return nil
+	g.formatted = formatted
+	return g.formatted, nil
 }
 
 // Returns the expected output file path and name
@@ -210,7 +250,7 @@ func (g *GeneratorFile) generateHeader() {
 		p = g.G().GoWrapFilePackage(g.G().GetDepFile())
 	}
 
-	g.P("// Code generated by fproto-gowrap. DO NOT EDIT.")
+	g.P("// ", GENERATED_MARKER, ". DO NOT EDIT.")
 	if g.G().GetDepFile() != nil {
 		g.P("// source file: ", g.G().GetDepFile().FilePath)
 	}
@@ -0,0 +1,284 @@
+package fproto_gowrap
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/RangelReale/fdep"
+	"github.com/RangelReale/fproto"
+)
+
+// How Go identifier collisions caused by the CamelCase naming are resolved
+type NameCollision int
+
+const (
+	// Fails the generation, reporting both colliding elements
+	NAMECOLLISION_ERROR NameCollision = iota
+	// Appends "_" to the identifiers created by the wrapper (the _Import and extension functions, the Export, Has,
+	// Clear and Get methods, the enum Parse and _Values functions, and the Customizer_Names ones) until they are unique.
+	// Collisions between names of proto elements are always errors, as they must match the protoc-gen-go names.
+	NAMECOLLISION_SUFFIX
+)
+
+// Parses a NameCollision name: "error" (or blank) or "suffix"
+func ParseNameCollision(name string) (NameCollision, error) {
+	switch name {
+	case "", "error":
+		return NAMECOLLISION_ERROR, nil
+	case "suffix":
+		return NAMECOLLISION_SUFFIX, nil
+	}
+	return NAMECOLLISION_ERROR, fmt.Errorf("Invalid name collision resolution: %s", name)
+}
+
+type nameKind int
+
+const (
+	// proto elements, named like the protoc-gen-go generated ones
+	nameKind_Proto nameKind = iota
+	// oneof field structs, which protoc-gen-go suffixes with "_" if they collide with a message or enum
+	nameKind_OneOfField
+	// identifiers created by the wrapper
+	nameKind_Wrapper
+)
+
+type nameKey struct {
+	scope string
+	name  string
+}
+
+type nameEntry struct {
+	nameKey
+	kind nameKind
+	// message or enum type
+	isType bool
+	// element description, for errors
+	desc string
+	// for identifiers derived from the name of a oneof field struct, which may be renamed
+	derived func() nameKey
+	// registered name
+	resolved string
+}
+
+// Go identifiers of the owned files, by Go package and by struct.
+// They are collected before the generation, so the renamed identifiers don't depend on the generation order.
+type Names struct {
+	names   map[nameKey]*nameEntry
+	renamed map[nameKey]string
+}
+
+// Registers the identifiers in order: proto elements, oneof field structs, and identifiers created by the wrapper.
+// Entries of the same kind are registered in the order they were collected.
+func newNames(entries []*nameEntry, collision NameCollision) (*Names, error) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].kind < entries[j].kind
+	})
+
+	ret := &Names{
+		names:   make(map[nameKey]*nameEntry),
+		renamed: make(map[nameKey]string),
+	}
+
+	for _, e := range entries {
+		if e.derived != nil {
+			e.nameKey = e.derived()
+		}
+
+		key := e.nameKey
+		for {
+			existing, ok := ret.names[key]
+			if !ok {
+				break
+			}
+
+			rename := false
+			switch e.kind {
+			case nameKind_OneOfField:
+				rename = existing.kind == nameKind_Proto && existing.isType
+			case nameKind_Wrapper:
+				rename = existing.kind != nameKind_Wrapper && collision == NAMECOLLISION_SUFFIX
+			}
+			if !rename {
+				return nil, fmt.Errorf("Go identifier collision on %s: %s and %s are both named '%s'", e.scope, existing.desc, e.desc, key.name)
+			}
+
+			key.name += "_"
+		}
+
+		ret.names[key] = e
+		e.resolved = key.name
+		if key.name != e.name {
+			ret.renamed[e.nameKey] = key.name
+		}
+	}
+
+	return ret, nil
+}
+
+// Returns the identifier to use, which may have been renamed to avoid a collision
+func (n *Names) get(scope string, name string) string {
+	if rn, ok := n.renamed[nameKey{scope, name}]; ok {
+		return rn
+	}
+	return name
+}
+
+// Returns the scope of a Go identifier: the Go wrap package of the file, or a struct in it.
+func (g *Generator) nameScope(depfile *fdep.DepFile, structName string) string {
+	pkg := g.GoWrapPackage(depfile)
+	if structName == "" {
+		return "package " + pkg
+	}
+	return "struct " + pkg + "." + structName
+}
+
+// Returns a Go identifier of the wrap package of the file, or of a struct in it if structName is not blank,
+// renamed if it collided with another one.
+func (g *Generator) wrapName(depfile *fdep.DepFile, structName string, name string) string {
+	if g.Names == nil {
+		return name
+	}
+	return g.Names.get(g.nameScope(depfile, structName), name)
+}
+
+// Returns a Go identifier of the wrap package of the file, or of a struct in it if structName is not blank,
+// renamed if it collided with another one. Identifiers generated by customizers must be returned by
+// Customizer_Names to be renamed.
+func (g *Generator) WrapName(depfile *fdep.DepFile, structName string, name string) string {
+	return g.wrapName(depfile, structName, name)
+}
+
+// Collects the Go identifiers generated for the current file
+func (g *Generator) nameEntries() ([]*nameEntry, error) {
+	var ret []*nameEntry
+	add := func(structName string, name string, kind nameKind, isType bool, format string, args ...interface{}) *nameEntry {
+		e := &nameEntry{
+			nameKey: nameKey{g.nameScope(g.depfile, structName), name},
+			kind:    kind,
+			isType:  isType,
+			desc:    fmt.Sprintf(format, args...) + " (" + g.depfile.FilePath + ")",
+		}
+		ret = append(ret, e)
+		return e
+	}
+	pkgScope := g.nameScope(g.depfile, "")
+
+	for _, e := range g.depfile.ProtoFile.CollectEnums() {
+		enum := e.(*fproto.EnumElement)
+		enGoName, enProtoName := g.BuildEnumName(enum)
+
+		add("", enGoName, nameKind_Proto, true, "enum '%s'", enProtoName)
+		add("", enGoName+"_name", nameKind_Proto, false, "names of enum '%s'", enProtoName)
+		add("", enGoName+"_value", nameKind_Proto, false, "values of enum '%s'", enProtoName)
+		for _, ec := range enum.EnumConstants {
+			ecGoName, ecProtoName := g.BuildEnumConstantName(ec)
+			add("", ecGoName, nameKind_Proto, false, "enum constant '%s'", ecProtoName)
+		}
+
+		if g.EnumWrap {
+			add("", "Parse"+enGoName, nameKind_Wrapper, false, "parse function of enum '%s'", enProtoName)
+			add("", enGoName+"_Values", nameKind_Wrapper, false, "values function of enum '%s'", enProtoName)
+		}
+	}
+
+	for _, m := range g.depfile.ProtoFile.CollectMessages() {
+		message := m.(*fproto.MessageElement)
+
+		if message.IsExtend {
+			tp_extendee, _, scopeGoName, err := g.extendTarget(message)
+			if err != nil {
+				return nil, err
+			}
+			if !g.IsFileWrap(tp_extendee.DepFile) {
+				continue
+			}
+
+			for _, fld := range message.Fields {
+				fldGoName, fldProtoName := g.BuildFieldName(fld)
+				for _, prefix := range []string{"GetExtension_", "SetExtension_", "ClearExtension_"} {
+					add("", prefix+scopeGoName+fldGoName, nameKind_Wrapper, false, "accessor of extension '%s.%s'", message.Name, fldProtoName)
+				}
+			}
+			continue
+		}
+
+		tp_msg := g.dep.DepTypeFromElement(message)
+		if tp_msg == nil {
+			return nil, fmt.Errorf("message type not found")
+		}
+
+		msgGoName, msgProtoName := g.BuildMessageName(message)
+
+		add("", msgGoName, nameKind_Proto, true, "message '%s'", msgProtoName)
+		add("", msgGoName+"_Import", nameKind_Wrapper, false, "import function of message '%s'", msgProtoName)
+		add(msgGoName, "Export", nameKind_Wrapper, false, "export method of message '%s'", msgProtoName)
+		if len(message.Extensions) > 0 {
+			add(msgGoName, "XXX_Extensions", nameKind_Proto, false, "extensions of message '%s'", msgProtoName)
+		}
+		if g.KeepUnknownFields {
+			add(msgGoName, "XXX_unrecognized", nameKind_Proto, false, "unknown fields of message '%s'", msgProtoName)
+		}
+
+		for _, fld := range message.Fields {
+			fldGoName, fldProtoName := g.BuildFieldName(fld)
+			add(msgGoName, fldGoName, nameKind_Proto, false, "field '%s.%s'", msgProtoName, fldProtoName)
+
+			switch xfld := fld.(type) {
+			case *fproto.FieldElement:
+				tp_fld, err := tp_msg.GetType(xfld.Type)
+				if err != nil {
+					return nil, err
+				}
+
+				fieldType, presence, getter, err := g.fieldHelpers(g.FMain(), tp_fld, xfld)
+				if err != nil {
+					return nil, err
+				}
+				if fieldType == "" {
+					continue
+				}
+				if presence {
+					add(msgGoName, "Has"+fldGoName, nameKind_Wrapper, false, "has method of field '%s.%s'", msgProtoName, fldProtoName)
+					add(msgGoName, "Clear"+fldGoName, nameKind_Wrapper, false, "clear method of field '%s.%s'", msgProtoName, fldProtoName)
+				}
+				if getter {
+					add(msgGoName, "Get"+fldGoName, nameKind_Wrapper, false, "get method of field '%s.%s'", msgProtoName, fldProtoName)
+				}
+			case *fproto.OneOfFieldElement:
+				ooGoName, ooProtoName := g.BuildOneOfName(xfld)
+				add("", ooGoName, nameKind_Proto, false, "oneof '%s'", ooProtoName)
+
+				for _, oofld := range xfld.Fields {
+					oofldGoName, _ := g.BuildFieldName(oofld)
+					oneofFieldGoName, oneofFieldProtoName := g.BuildOneOfFieldName(oofld)
+
+					// the oneof field struct may be renamed, so the identifiers derived from it are resolved later
+					oe := add("", oneofFieldGoName, nameKind_OneOfField, false, "oneof field '%s'", oneofFieldProtoName)
+					add("", "", nameKind_OneOfField, false, "field of oneof field '%s'", oneofFieldProtoName).derived = func() nameKey {
+						return nameKey{g.nameScope(g.depfile, oe.resolved), oofldGoName}
+					}
+					add("", "", nameKind_Wrapper, false, "import function of oneof field '%s'", oneofFieldProtoName).derived = func() nameKey {
+						return nameKey{pkgScope, oe.resolved + "_Import"}
+					}
+					add("", "", nameKind_Wrapper, false, "export method of oneof field '%s'", oneofFieldProtoName).derived = func() nameKey {
+						return nameKey{g.nameScope(g.depfile, oe.resolved), "Export"}
+					}
+				}
+			}
+		}
+
+		for _, cz := range g.Customizers {
+			if czn, ok := cz.(Customizer_Names); ok {
+				cnames, err := czn.MessageNames(g, message)
+				if err != nil {
+					return nil, g.elementError(message, err)
+				}
+				for _, cn := range cnames {
+					add(cn.StructName, cn.Name, nameKind_Wrapper, false, "%s", cn.Desc)
+				}
+			}
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,330 @@
+package fproto_gowrap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Source of the positions of the proto elements, used on GenerationError and on the source maps.
+// The elements are identified by the proto file path and the element path, like "Message.field" (see GenerationError).
+type PositionSource interface {
+	// Returns the line and column of the element, starting at 1
+	GetPosition(file string, element string) (line int, column int, ok bool)
+}
+
+// Position of an element on a proto file
+type sourcePosition struct {
+	line   int
+	column int
+}
+
+//
+// PositionSource: Map
+//
+
+// Position source with the positions added explicitly, like from the SourceCodeInfo of a protoc request.
+// Must not be changed while generating.
+type PositionSource_Map struct {
+	files map[string]map[string]sourcePosition
+}
+
+func NewPositionSource_Map() *PositionSource_Map {
+	return &PositionSource_Map{
+		files: make(map[string]map[string]sourcePosition),
+	}
+}
+
+// Adds the position of an element. The first position of an element is kept.
+func (p *PositionSource_Map) Add(file string, element string, line int, column int) {
+	elements, ok := p.files[file]
+	if !ok {
+		elements = make(map[string]sourcePosition)
+		p.files[file] = elements
+	}
+	if _, ok := elements[element]; !ok {
+		elements[element] = sourcePosition{line: line, column: column}
+	}
+}
+
+func (p *PositionSource_Map) GetPosition(file string, element string) (line int, column int, ok bool) {
+	pos, ok := p.files[file][element]
+	return pos.line, pos.column, ok
+}
+
+//
+// PositionSource: Scan
+//
+
+// Position source that scans the proto source files for the element declarations. The files are searched on
+// Dirs, in order, and each file is scanned once, on the first position requested from it.
+type PositionSource_Scan struct {
+	Dirs []string
+
+	lock  sync.Mutex
+	files map[string]map[string]sourcePosition
+}
+
+func NewPositionSource_Scan(dirs ...string) *PositionSource_Scan {
+	return &PositionSource_Scan{
+		Dirs:  dirs,
+		files: make(map[string]map[string]sourcePosition),
+	}
+}
+
+func (p *PositionSource_Scan) GetPosition(file string, element string) (line int, column int, ok bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	elements, scanned := p.files[file]
+	if !scanned {
+		elements = p.scanFile(file)
+		p.files[file] = elements
+	}
+
+	pos, ok := elements[element]
+	return pos.line, pos.column, ok
+}
+
+// Scans the file from the first directory that contains it. Files that can't be read have no positions.
+func (p *PositionSource_Scan) scanFile(file string) map[string]sourcePosition {
+	for _, dir := range p.Dirs {
+		src, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil
+		}
+		return scanProtoPositions(src)
+	}
+	return nil
+}
+
+//
+// Proto source scanner
+//
+
+// A token of the proto source
+type protoToken struct {
+	text   string
+	line   int
+	column int
+}
+
+// Splits the proto source into tokens, skipping the comments. Identifiers keep their dots, like "google.protobuf.Any".
+func tokenizeProto(src []byte) []protoToken {
+	var ret []protoToken
+
+	line, column := 1, 1
+	advance := func(n int, i int) int {
+		for j := i; j < i+n && j < len(src); j++ {
+			if src[j] == '\n' {
+				line++
+				column = 1
+			} else {
+				column++
+			}
+		}
+		return i + n
+	}
+
+	isIdent := func(c byte) bool {
+		return c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i = advance(1, i)
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			n := 0
+			for i+n < len(src) && src[i+n] != '\n' {
+				n++
+			}
+			i = advance(n, i)
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			n := 2
+			for i+n < len(src) && !(src[i+n] == '*' && i+n+1 < len(src) && src[i+n+1] == '/') {
+				n++
+			}
+			i = advance(n+2, i)
+		case c == '"' || c == '\'':
+			n := 1
+			for i+n < len(src) && src[i+n] != c && src[i+n] != '\n' {
+				if src[i+n] == '\\' {
+					n++
+				}
+				n++
+			}
+			if i+n < len(src) {
+				n++
+			}
+			ret = append(ret, protoToken{text: string(src[i : i+n]), line: line, column: column})
+			i = advance(n, i)
+		case isIdent(c):
+			n := 0
+			for i+n < len(src) && isIdent(src[i+n]) {
+				n++
+			}
+			ret = append(ret, protoToken{text: string(src[i : i+n]), line: line, column: column})
+			i = advance(n, i)
+		default:
+			ret = append(ret, protoToken{text: string(c), line: line, column: column})
+			i = advance(1, i)
+		}
+	}
+
+	return ret
+}
+
+// Returns the positions of the element declarations of the proto source, keyed by element path: messages, enums
+// and their values, services and their rpcs, fields, oneofs, and extend blocks and their fields.
+// Fields inside oneofs are added both as "Message.oneof.field" and as "Message.field".
+func scanProtoPositions(src []byte) map[string]sourcePosition {
+	tokens := tokenizeProto(src)
+	ret := make(map[string]sourcePosition)
+
+	add := func(path string, tk protoToken) {
+		if _, ok := ret[path]; !ok {
+			ret[path] = sourcePosition{line: tk.line, column: tk.column}
+		}
+	}
+
+	// the scopes of the open blocks. Blocks that don't declare elements have an empty kind.
+	type scope struct {
+		kind string
+		path string
+	}
+	var scopes []scope
+	current := func() scope {
+		if len(scopes) == 0 {
+			return scope{kind: "file"}
+		}
+		return scopes[len(scopes)-1]
+	}
+	join := func(parent, name string) string {
+		if parent == "" {
+			return name
+		}
+		return parent + "." + name
+	}
+
+	// returns the index after the end of the statement starting at i, and its terminator, ";" or "{"
+	statementEnd := func(i int) (int, string) {
+		depth := 0
+		for ; i < len(tokens); i++ {
+			switch tokens[i].text {
+			case "(", "[":
+				depth++
+			case ")", "]":
+				depth--
+			case "{":
+				if depth == 0 {
+					return i + 1, "{"
+				}
+				depth++
+			case "}":
+				if depth == 0 {
+					return i, "}"
+				}
+				depth--
+			case ";":
+				if depth == 0 {
+					return i + 1, ";"
+				}
+			}
+		}
+		return i, ""
+	}
+	// skips a statement whose value can have braces, like an option, up to its ";"
+	skipStatement := func(i int) int {
+		depth := 0
+		for ; i < len(tokens); i++ {
+			switch tokens[i].text {
+			case "(", "[", "{":
+				depth++
+			case ")", "]":
+				depth--
+			case "}":
+				if depth == 0 {
+					return i
+				}
+				depth--
+			case ";":
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return i
+	}
+
+	for i := 0; i < len(tokens); {
+		tk := tokens[i]
+		sc := current()
+
+		switch tk.text {
+		case ";":
+			i++
+			continue
+		case "}":
+			if len(scopes) > 0 {
+				scopes = scopes[:len(scopes)-1]
+			}
+			i++
+			continue
+		case "syntax", "package", "import", "option", "reserved", "extensions":
+			i = skipStatement(i)
+			continue
+		}
+
+		end, term := statementEnd(i)
+		stmt := tokens[i:end]
+		if term == "{" || term == ";" {
+			stmt = stmt[:len(stmt)-1]
+		}
+
+		newscope := scope{}
+		switch {
+		case (tk.text == "message" || tk.text == "enum" || tk.text == "service") && len(stmt) > 1 && term == "{" &&
+			(sc.kind == "file" || sc.kind == "message"):
+			newscope = scope{kind: tk.text, path: join(sc.path, stmt[1].text)}
+			add(newscope.path, tk)
+		case tk.text == "extend" && len(stmt) > 1 && term == "{":
+			newscope = scope{kind: "extend", path: "extend " + stmt[1].text}
+			add(newscope.path, tk)
+		case tk.text == "oneof" && len(stmt) > 1 && term == "{" && sc.kind == "message":
+			newscope = scope{kind: "oneof", path: join(sc.path, stmt[1].text)}
+			add(newscope.path, tk)
+		case tk.text == "rpc" && len(stmt) > 1 && sc.kind == "service":
+			add(join(sc.path, stmt[1].text), tk)
+		case sc.kind == "enum" && len(stmt) > 1 && stmt[1].text == "=":
+			add(join(sc.path, tk.text), tk)
+		case sc.kind == "message" || sc.kind == "oneof" || sc.kind == "extend":
+			// field: the name is before the "=" at the top level of the statement
+			for j := 1; j < len(stmt); j++ {
+				if stmt[j].text == "(" || stmt[j].text == "[" {
+					break
+				}
+				if stmt[j].text == "=" {
+					name := stmt[j-1].text
+					add(join(sc.path, name), tk)
+					if sc.kind == "oneof" {
+						add(join(scopes[len(scopes)-2].path, name), tk)
+					}
+					break
+				}
+			}
+		}
+
+		if term == "{" {
+			scopes = append(scopes, newscope)
+		}
+		i = end
+	}
+
+	return ret
+}
@@ -0,0 +1,64 @@
+package fproto_gowrap
+
+import "testing"
+
+func TestScanProtoPositions(t *testing.T) {
+	src := `syntax = "proto3";
+package core; // message Commented {
+option (my.opt) = { a: 1 nested { b: "}" } };
+
+/* message Commented { } */
+message User {
+  string name = 1 [(x) = { y: 1 }];
+  map<string, int32> tags = 2;
+  oneof contact {
+    string email = 3;
+  }
+  enum Status {
+    option allow_alias = true;
+    ACTIVE = 0;
+  }
+  message Address { string city = 1; }
+  reserved 10 to 12;
+  extend .core.Other {
+    string note = 100;
+  }
+}
+
+service Users {
+  rpc Get(User) returns (User);
+  rpc List(User) returns (stream User) { option deprecated = true; }
+}
+`
+	expected := map[string]sourcePosition{
+		"User":                    {6, 1},
+		"User.name":               {7, 3},
+		"User.tags":               {8, 3},
+		"User.contact":            {9, 3},
+		"User.contact.email":      {10, 5},
+		"User.email":              {10, 5},
+		"User.Status":             {12, 3},
+		"User.Status.ACTIVE":      {14, 5},
+		"User.Address":            {16, 3},
+		"User.Address.city":       {16, 21},
+		"extend .core.Other":      {18, 3},
+		"extend .core.Other.note": {19, 5},
+		"Users":                   {23, 1},
+		"Users.Get":               {24, 3},
+		"Users.List":              {25, 3},
+	}
+
+	positions := scanProtoPositions([]byte(src))
+	for element, pos := range expected {
+		if got, ok := positions[element]; !ok {
+			t.Errorf("%s: position not found", element)
+		} else if got != pos {
+			t.Errorf("%s: expected position %d:%d, got %d:%d", element, pos.line, pos.column, got.line, got.column)
+		}
+	}
+	for element := range positions {
+		if _, ok := expected[element]; !ok {
+			t.Errorf("%s: unexpected element", element)
+		}
+	}
+}
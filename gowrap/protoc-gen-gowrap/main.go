@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/RangelReale/fdep"
+	"github.com/RangelReale/fproto-wrap/gowrap"
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// Plugin parameters, passed as comma-separated key=value pairs.
+// "M<proto file>=<go wrap package>" sets the Go wrap package of a proto file, and
+// "services=<name>" generates the service wrappers using a registered service generator (ex: grpc), and
+// "keep_unknown_fields" keeps the unknown fields of the source messages on the wrapped structs, and
+// "enum_wrap" generates the enums as new types with helper methods.
+// "name_collision=suffix" renames the wrapper identifiers that collide with others instead of failing.
+// "source_map" writes a side-car source map for each generated file, and "position_comments" adds the proto
+// positions as comments.
+// "wrap_imported" also wraps the imported files, and "W<proto file>=<go wrap package>" sets the Go wrap package of a
+// file wrapped outside of this run.
+type params struct {
+	pkgSource         *fproto_gowrap.PkgSource_Map
+	services          string
+	keepUnknownFields bool
+	enumWrap          bool
+	nameCollision     fproto_gowrap.NameCollision
+	sourceMap         bool
+	positionComments  bool
+	wrapImported      bool
+	externalWrap      map[string]string
+}
+
+func parseParams(parameter string) (*params, error) {
+	ret := &params{
+		pkgSource:    fproto_gowrap.NewPkgSource_Map(),
+		externalWrap: make(map[string]string),
+	}
+
+	for _, p := range strings.Split(parameter, ",") {
+		if p == "" {
+			continue
+		}
+
+		var key, value string
+		if i := strings.Index(p, "="); i >= 0 {
+			key, value = p[:i], p[i+1:]
+		} else {
+			key = p
+		}
+
+		switch {
+		case strings.HasPrefix(key, "M"):
+			ret.pkgSource.Packages[key[1:]] = value
+		case strings.HasPrefix(key, "W"):
+			ret.externalWrap[key[1:]] = value
+		case key == "services":
+			if _, ok := fproto_gowrap.LookupServiceGen(value); !ok {
+				return nil, fmt.Errorf("Unknown service generator: %s", value)
+			}
+			ret.services = value
+		case key == "keep_unknown_fields":
+			ret.keepUnknownFields = value == "" || value == "true"
+		case key == "enum_wrap":
+			ret.enumWrap = value == "" || value == "true"
+		case key == "name_collision":
+			nc, err := fproto_gowrap.ParseNameCollision(value)
+			if err != nil {
+				return nil, err
+			}
+			ret.nameCollision = nc
+		case key == "source_map":
+			ret.sourceMap = value == "" || value == "true"
+		case key == "position_comments":
+			ret.positionComments = value == "" || value == "true"
+		case key == "wrap_imported":
+			ret.wrapImported = value == "" || value == "true"
+		default:
+			return nil, fmt.Errorf("Unknown parameter: %s", key)
+		}
+	}
+
+	return ret, nil
+}
+
+// Usage:
+// protoc --plugin=protoc-gen-gowrap --gowrap_out=services=grpc:/mysource/proto_wrappers -I/mysource/proto /mysource/proto/*.proto
+func main() {
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatalf("Error reading input: %v", err)
+	}
+
+	req := &plugin.CodeGeneratorRequest{}
+	err = proto.Unmarshal(data, req)
+	if err != nil {
+		log.Fatalf("Error parsing input: %v", err)
+	}
+
+	resp, err := generate(req)
+	if err != nil {
+		resp = &plugin.CodeGeneratorResponse{
+			Error:             proto.String(err.Error()),
+			SupportedFeatures: proto.Uint64(uint64(plugin.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)),
+		}
+	}
+
+	data, err = proto.Marshal(resp)
+	if err != nil {
+		log.Fatalf("Error encoding output: %v", err)
+	}
+
+	_, err = os.Stdout.Write(data)
+	if err != nil {
+		log.Fatalf("Error writing output: %v", err)
+	}
+}
+
+func generate(req *plugin.CodeGeneratorRequest) (*plugin.CodeGeneratorResponse, error) {
+	p, err := parseParams(req.GetParameter())
+	if err != nil {
+		return nil, err
+	}
+
+	// fdep parses .proto sources, so the descriptors are written back as source files on a temporary directory.
+	// Files to generate are added as owned, the others are only available as includes.
+	tmpPath, err := ioutil.TempDir("", "protoc-gen-gowrap")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpPath)
+
+	ownPath := filepath.Join(tmpPath, "own")
+	incPath := filepath.Join(tmpPath, "inc")
+
+	own := make(map[string]bool)
+	for _, fn := range req.FileToGenerate {
+		own[fn] = true
+	}
+
+	positions := fproto_gowrap.NewPositionSource_Map()
+	options := newOptionRenderer(req.ProtoFile)
+	for _, fd := range req.ProtoFile {
+		addPositions(positions, fd)
+
+		fp := filepath.Join(incPath, filepath.FromSlash(fd.GetName()))
+		if own[fd.GetName()] {
+			fp = filepath.Join(ownPath, filepath.FromSlash(fd.GetName()))
+		}
+
+		err = os.MkdirAll(filepath.Dir(fp), os.ModePerm)
+		if err != nil {
+			return nil, err
+		}
+
+		src, err := buildSource(fd, options)
+		if err != nil {
+			return nil, err
+		}
+
+		err = ioutil.WriteFile(fp, src, 0644)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	parsedep := fdep.NewDep()
+	parsedep.IncludeDirs = append(parsedep.IncludeDirs, incPath)
+
+	if len(own) > 0 {
+		err = parsedep.AddPath(ownPath, fdep.DepType_Own)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = parsedep.CheckDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	// creates the wrapper generator
+	w := fproto_gowrap.NewWrapper(parsedep)
+	w.PkgSource = p.pkgSource
+	w.KeepUnknownFields = p.keepUnknownFields
+	w.EnumWrap = p.enumWrap
+	w.NameCollision = p.nameCollision
+	w.SourceMap = p.sourceMap
+	w.PositionComments = p.positionComments
+	w.Positions = positions
+	w.WrapImported = p.wrapImported
+	w.ExternalWrap = p.externalWrap
+	if p.services != "" {
+		w.ServiceGen, err = fproto_gowrap.NewServiceGen(p.services, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	output := fproto_gowrap.NewFileOutput_Memory()
+	err = w.Generate(output)
+	if err != nil {
+		return nil, err
+	}
+
+	// proto3 optional fields are wrapped as pointers
+	resp := &plugin.CodeGeneratorResponse{
+		SupportedFeatures: proto.Uint64(uint64(plugin.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)),
+	}
+	err = output.Each(func(filename string, content []byte) error {
+		resp.File = append(resp.File, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(filename),
+			Content: proto.String(string(content)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
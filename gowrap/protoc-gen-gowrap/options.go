@@ -0,0 +1,418 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	godescriptor "github.com/golang/protobuf/descriptor"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Full names of the option messages
+const (
+	options_File      = ".google.protobuf.FileOptions"
+	options_Message   = ".google.protobuf.MessageOptions"
+	options_Field     = ".google.protobuf.FieldOptions"
+	options_Oneof     = ".google.protobuf.OneofOptions"
+	options_Enum      = ".google.protobuf.EnumOptions"
+	options_EnumValue = ".google.protobuf.EnumValueOptions"
+	options_Service   = ".google.protobuf.ServiceOptions"
+	options_Method    = ".google.protobuf.MethodOptions"
+
+	// uninterpreted_option, only set on descriptors that were not fully parsed
+	options_UninterpretedOption = 999
+)
+
+// Renders the options of the descriptors back to proto source, including the custom options, which are extensions
+// of the option messages declared on the request files. The options are decoded from their wire format, so the
+// custom options don't need to be linked in.
+type optionRenderer struct {
+	// by full name, with the leading "."
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+	// by extendee full name and field number
+	extensions map[string]map[int32]*optionExtension
+}
+
+// An extension of an option message
+type optionExtension struct {
+	// full name, without the leading "."
+	name  string
+	field *descriptor.FieldDescriptorProto
+}
+
+// A decoded option field
+type optionValue struct {
+	name  string
+	isExt bool
+	value string
+}
+
+func newOptionRenderer(files []*descriptor.FileDescriptorProto) *optionRenderer {
+	r := &optionRenderer{
+		messages:   make(map[string]*descriptor.DescriptorProto),
+		enums:      make(map[string]*descriptor.EnumDescriptorProto),
+		extensions: make(map[string]map[int32]*optionExtension),
+	}
+
+	// descriptor.proto, for the standard options
+	fd, _ := godescriptor.ForMessage(&descriptor.FileOptions{})
+	r.addFile(fd)
+
+	for _, f := range files {
+		r.addFile(f)
+	}
+	return r
+}
+
+func (r *optionRenderer) addFile(fd *descriptor.FileDescriptorProto) {
+	var scope string
+	if fd.GetPackage() != "" {
+		scope = "." + fd.GetPackage()
+	}
+
+	for _, enum := range fd.EnumType {
+		r.addEnum(scope, enum)
+	}
+	for _, msg := range fd.MessageType {
+		r.addMessage(scope, msg)
+	}
+	for _, ext := range fd.Extension {
+		r.addExtension(scope, ext)
+	}
+}
+
+func (r *optionRenderer) addEnum(scope string, enum *descriptor.EnumDescriptorProto) {
+	name := scope + "." + enum.GetName()
+	if _, ok := r.enums[name]; !ok {
+		r.enums[name] = enum
+	}
+}
+
+func (r *optionRenderer) addMessage(scope string, msg *descriptor.DescriptorProto) {
+	name := scope + "." + msg.GetName()
+	if _, ok := r.messages[name]; ok {
+		return
+	}
+	r.messages[name] = msg
+
+	for _, enum := range msg.EnumType {
+		r.addEnum(name, enum)
+	}
+	for _, nested := range msg.NestedType {
+		r.addMessage(name, nested)
+	}
+	for _, ext := range msg.Extension {
+		r.addExtension(name, ext)
+	}
+}
+
+func (r *optionRenderer) addExtension(scope string, ext *descriptor.FieldDescriptorProto) {
+	fields, ok := r.extensions[ext.GetExtendee()]
+	if !ok {
+		fields = make(map[int32]*optionExtension)
+		r.extensions[ext.GetExtendee()] = fields
+	}
+	if _, ok := fields[ext.GetNumber()]; !ok {
+		fields[ext.GetNumber()] = &optionExtension{
+			name:  strings.TrimPrefix(scope+"."+ext.GetName(), "."),
+			field: ext,
+		}
+	}
+}
+
+// Returns the options set on the option message as "name = value" items, in wire order. Custom options are named
+// as "(package.name)". Repeated options have one item for each value.
+func (r *optionRenderer) options(opts proto.Message, typeName string) ([]string, error) {
+	if opts == nil || reflect.ValueOf(opts).IsNil() {
+		return nil, nil
+	}
+
+	data, err := proto.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := r.decode(data, typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	for _, v := range values {
+		if v.isExt {
+			ret = append(ret, "("+v.name+") = "+v.value)
+		} else {
+			ret = append(ret, v.name+" = "+v.value)
+		}
+	}
+	return ret, nil
+}
+
+// Decodes the fields of a message of the type
+func (r *optionRenderer) decode(data []byte, typeName string) ([]optionValue, error) {
+	md, ok := r.messages[typeName]
+	if !ok {
+		return nil, fmt.Errorf("Unknown option type %s", strings.TrimPrefix(typeName, "."))
+	}
+
+	var ret []optionValue
+	w := &wireReader{data: data}
+	for len(w.data) > 0 {
+		tag, err := w.varint()
+		if err != nil {
+			return nil, err
+		}
+		number, wireType := int32(tag>>3), int(tag&7)
+
+		if number == options_UninterpretedOption && isOptionsType(typeName) {
+			if err := w.skip(wireType); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var field *descriptor.FieldDescriptorProto
+		var name string
+		var isExt bool
+		for _, f := range md.Field {
+			if f.GetNumber() == number {
+				field, name = f, f.GetName()
+				break
+			}
+		}
+		if field == nil {
+			ext, ok := r.extensions[typeName][number]
+			if !ok {
+				return nil, fmt.Errorf("Unknown field %d of option type %s", number, strings.TrimPrefix(typeName, "."))
+			}
+			field, name, isExt = ext.field, ext.name, true
+		}
+
+		values, err := r.decodeField(w, wireType, field)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding option %s: %v", name, err)
+		}
+		for _, v := range values {
+			ret = append(ret, optionValue{name: name, isExt: isExt, value: v})
+		}
+	}
+	return ret, nil
+}
+
+// Decodes the value of the field, in proto text format. Packed repeated fields return all their values.
+func (r *optionRenderer) decodeField(w *wireReader, wireType int, field *descriptor.FieldDescriptorProto) ([]string, error) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return nil, fmt.Errorf("group options are not supported")
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		b, err := w.bytes(wireType)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"\"" + cEscape(string(b)) + "\""}, nil
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		b, err := w.bytes(wireType)
+		if err != nil {
+			return nil, err
+		}
+		values, err := r.decode(b, field.GetTypeName())
+		if err != nil {
+			return nil, err
+		}
+		items := make([]string, 0, len(values))
+		for _, v := range values {
+			if v.isExt {
+				items = append(items, "["+v.name+"]: "+v.value)
+			} else {
+				items = append(items, v.name+": "+v.value)
+			}
+		}
+		if len(items) == 0 {
+			return []string{"{}"}, nil
+		}
+		return []string{"{ " + strings.Join(items, " ") + " }"}, nil
+	}
+
+	// packed repeated scalars
+	if wireType == wire_Bytes {
+		b, err := w.bytes(wireType)
+		if err != nil {
+			return nil, err
+		}
+		packed := &wireReader{data: b}
+		var ret []string
+		for len(packed.data) > 0 {
+			v, err := r.decodeScalar(packed, scalarWireType(field), field)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, v)
+		}
+		return ret, nil
+	}
+
+	v, err := r.decodeScalar(w, wireType, field)
+	if err != nil {
+		return nil, err
+	}
+	return []string{v}, nil
+}
+
+func (r *optionRenderer) decodeScalar(w *wireReader, wireType int, field *descriptor.FieldDescriptorProto) (string, error) {
+	if wireType != scalarWireType(field) {
+		return "", fmt.Errorf("invalid wire type %d", wireType)
+	}
+
+	var u uint64
+	var err error
+	switch wireType {
+	case wire_Fixed32:
+		u, err = w.fixed32()
+	case wire_Fixed64:
+		u, err = w.fixed64()
+	default:
+		u, err = w.varint()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return formatFloat(math.Float64frombits(u), 64), nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return formatFloat(float64(math.Float32frombits(uint32(u))), 32), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return strconv.FormatInt(int64(u), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return strconv.FormatInt(int64(int32(u)), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return strconv.FormatUint(uint64(uint32(u)), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		return strconv.FormatInt(int64(int32(uint32(u)>>1)^-int32(u&1)), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return strconv.FormatInt(int64(u>>1)^-int64(u&1), 10), nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return strconv.FormatBool(u != 0), nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if enum, ok := r.enums[field.GetTypeName()]; ok {
+			for _, value := range enum.Value {
+				if value.GetNumber() == int32(u) {
+					return value.GetName(), nil
+				}
+			}
+		}
+		return strconv.FormatInt(int64(int32(u)), 10), nil
+	}
+	return strconv.FormatUint(u, 10), nil
+}
+
+// Returns whether the type is one of the google.protobuf option messages
+func isOptionsType(typeName string) bool {
+	return strings.HasPrefix(typeName, ".google.protobuf.") && strings.HasSuffix(typeName, "Options")
+}
+
+func formatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+//
+// Wire format reader
+//
+
+const (
+	wire_Varint  = 0
+	wire_Fixed64 = 1
+	wire_Bytes   = 2
+	wire_Fixed32 = 5
+)
+
+// Returns the wire type of the scalar field values
+func scalarWireType(field *descriptor.FieldDescriptorProto) int {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FIXED64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return wire_Fixed64
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT, descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return wire_Fixed32
+	}
+	return wire_Varint
+}
+
+type wireReader struct {
+	data []byte
+}
+
+func (w *wireReader) varint() (uint64, error) {
+	v, n := proto.DecodeVarint(w.data)
+	if n == 0 {
+		return 0, fmt.Errorf("invalid varint")
+	}
+	w.data = w.data[n:]
+	return v, nil
+}
+
+func (w *wireReader) fixed32() (uint64, error) {
+	if len(w.data) < 4 {
+		return 0, fmt.Errorf("unexpected end of data")
+	}
+	v := binary.LittleEndian.Uint32(w.data)
+	w.data = w.data[4:]
+	return uint64(v), nil
+}
+
+func (w *wireReader) fixed64() (uint64, error) {
+	if len(w.data) < 8 {
+		return 0, fmt.Errorf("unexpected end of data")
+	}
+	v := binary.LittleEndian.Uint64(w.data)
+	w.data = w.data[8:]
+	return v, nil
+}
+
+func (w *wireReader) bytes(wireType int) ([]byte, error) {
+	if wireType != wire_Bytes {
+		return nil, fmt.Errorf("invalid wire type %d", wireType)
+	}
+	l, err := w.varint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(w.data)) < l {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+	v := w.data[:l]
+	w.data = w.data[l:]
+	return v, nil
+}
+
+// Skips a value of the wire type
+func (w *wireReader) skip(wireType int) error {
+	var err error
+	switch wireType {
+	case wire_Varint:
+		_, err = w.varint()
+	case wire_Fixed64:
+		_, err = w.fixed64()
+	case wire_Bytes:
+		_, err = w.bytes(wireType)
+	case wire_Fixed32:
+		_, err = w.fixed32()
+	default:
+		err = fmt.Errorf("invalid wire type %d", wireType)
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"github.com/RangelReale/fproto-wrap/gowrap"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Adds the positions of the elements of the file from its SourceCodeInfo, keyed by the element paths of the rebuilt
+// source, so the errors and source maps point to the original .proto file.
+func addPositions(positions *fproto_gowrap.PositionSource_Map, fd *descriptor.FileDescriptorProto) {
+	if fd.SourceCodeInfo == nil {
+		return
+	}
+
+	spans := make(map[string][]int32)
+	for _, loc := range fd.SourceCodeInfo.Location {
+		key := pathKey(loc.Path)
+		if _, ok := spans[key]; !ok && len(loc.Span) >= 2 {
+			spans[key] = loc.Span
+		}
+	}
+
+	p := &positionBuilder{
+		fd:        fd,
+		positions: positions,
+		spans:     spans,
+	}
+
+	for i, enum := range fd.EnumType {
+		p.addEnum(enum, "", []int32{path_File_EnumType, int32(i)})
+	}
+	for i, msg := range fd.MessageType {
+		p.addMessage(msg, "", []int32{path_File_MessageType, int32(i)})
+	}
+	p.addExtensions(fd.Extension, []int32{path_File_Extension})
+	for i, svc := range fd.Service {
+		svcPath := []int32{path_File_Service, int32(i)}
+		p.add(svc.GetName(), svcPath)
+		for j, method := range svc.Method {
+			p.add(svc.GetName()+"."+method.GetName(), appendPath(svcPath, path_Service_Method, int32(j)))
+		}
+	}
+}
+
+// Adds the element positions of one file
+type positionBuilder struct {
+	fd        *descriptor.FileDescriptorProto
+	positions *fproto_gowrap.PositionSource_Map
+	spans     map[string][]int32
+}
+
+// Adds the position of the element from the span of the descriptor path, which is 0-based
+func (p *positionBuilder) add(element string, path []int32) {
+	if span, ok := p.spans[pathKey(path)]; ok {
+		p.positions.Add(p.fd.GetName(), element, int(span[0])+1, int(span[1])+1)
+	}
+}
+
+func (p *positionBuilder) addEnum(enum *descriptor.EnumDescriptorProto, parent string, path []int32) {
+	name := joinElement(parent, enum.GetName())
+	p.add(name, path)
+	for i, value := range enum.Value {
+		p.add(name+"."+value.GetName(), appendPath(path, path_Enum_Value, int32(i)))
+	}
+}
+
+func (p *positionBuilder) addMessage(msg *descriptor.DescriptorProto, parent string, path []int32) {
+	if msg.Options != nil && msg.Options.GetMapEntry() {
+		return
+	}
+
+	name := joinElement(parent, msg.GetName())
+	p.add(name, path)
+
+	for i, enum := range msg.EnumType {
+		p.addEnum(enum, name, appendPath(path, path_Message_EnumType, int32(i)))
+	}
+	for i, nested := range msg.NestedType {
+		p.addMessage(nested, name, appendPath(path, path_Message_NestedType, int32(i)))
+	}
+
+	for i, oneof := range msg.OneofDecl {
+		p.add(name+"."+oneof.GetName(), appendPath(path, path_Message_OneofDecl, int32(i)))
+	}
+	for i, field := range msg.Field {
+		fieldPath := appendPath(path, path_Message_Field, int32(i))
+		p.add(name+"."+field.GetName(), fieldPath)
+		// fields of the oneofs are children of the oneof on the rebuilt source
+		if field.OneofIndex != nil && !field.GetProto3Optional() && int(field.GetOneofIndex()) < len(msg.OneofDecl) {
+			p.add(name+"."+msg.OneofDecl[field.GetOneofIndex()].GetName()+"."+field.GetName(), fieldPath)
+		}
+	}
+
+	p.addExtensions(msg.Extension, appendPath(path, path_Message_Extension))
+}
+
+// Adds the extension fields as "extend <extendee>.<field>". The extend block has the position of its first field.
+func (p *positionBuilder) addExtensions(extensions []*descriptor.FieldDescriptorProto, path []int32) {
+	for i, ext := range extensions {
+		extendPath := "extend " + ext.GetExtendee()
+		p.add(extendPath, appendPath(path, int32(i)))
+		p.add(extendPath+"."+ext.GetName(), appendPath(path, int32(i)))
+	}
+}
+
+func joinElement(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Field numbers used on SourceCodeInfo paths
+const (
+	path_File_MessageType    = 4
+	path_File_EnumType       = 5
+	path_File_Service        = 6
+	path_File_Extension      = 7
+	path_Message_Field       = 2
+	path_Message_NestedType  = 3
+	path_Message_EnumType    = 4
+	path_Message_Extension   = 6
+	path_Message_OneofDecl   = 8
+	path_Enum_Value          = 2
+	path_Service_Method      = 2
+	path_Location_Separator  = ","
+	source_Indent            = "    "
+	source_ProtoSyntaxProto3 = "proto3"
+)
+
+// Renders a FileDescriptorProto back to .proto source, so it can be parsed by fdep.
+type sourceBuilder struct {
+	fd       *descriptor.FileDescriptorProto
+	options  *optionRenderer
+	comments map[string]string
+	buf      bytes.Buffer
+	indent   string
+	// first error, the source is not valid if set
+	err error
+}
+
+func buildSource(fd *descriptor.FileDescriptorProto, options *optionRenderer) ([]byte, error) {
+	s := &sourceBuilder{
+		fd:       fd,
+		options:  options,
+		comments: make(map[string]string),
+	}
+
+	if fd.SourceCodeInfo != nil {
+		for _, loc := range fd.SourceCodeInfo.Location {
+			if loc.LeadingComments != nil {
+				s.comments[pathKey(loc.Path)] = loc.GetLeadingComments()
+			}
+		}
+	}
+
+	s.generate()
+	if s.err != nil {
+		return nil, fmt.Errorf("Error rebuilding the source of %s: %v", fd.GetName(), s.err)
+	}
+	return s.buf.Bytes(), nil
+}
+
+func pathKey(path []int32) string {
+	var p []string
+	for _, i := range path {
+		p = append(p, strconv.Itoa(int(i)))
+	}
+	return strings.Join(p, path_Location_Separator)
+}
+
+func appendPath(path []int32, items ...int32) []int32 {
+	ret := make([]int32, 0, len(path)+len(items))
+	ret = append(ret, path...)
+	return append(ret, items...)
+}
+
+func (s *sourceBuilder) P(str ...string) {
+	if len(str) > 0 {
+		s.buf.WriteString(s.indent)
+	}
+	for _, v := range str {
+		s.buf.WriteString(v)
+	}
+	s.buf.WriteByte('\n')
+}
+
+func (s *sourceBuilder) In() { s.indent += source_Indent }
+
+func (s *sourceBuilder) Out() { s.indent = strings.TrimSuffix(s.indent, source_Indent) }
+
+func (s *sourceBuilder) comment(path []int32) {
+	c, ok := s.comments[pathKey(path)]
+	if !ok {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(c, "\n"), "\n") {
+		s.P("//", line)
+	}
+}
+
+// Keeps the first error
+func (s *sourceBuilder) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Returns the options as "name = value" items
+func (s *sourceBuilder) optionItems(opts proto.Message, typeName string) []string {
+	items, err := s.options.options(opts, typeName)
+	if err != nil {
+		s.fail(err)
+	}
+	return items
+}
+
+// Writes the options as option statements
+func (s *sourceBuilder) generateOptions(opts proto.Message, typeName string) {
+	for _, item := range s.optionItems(opts, typeName) {
+		s.P("option ", item, ";")
+	}
+}
+
+// Returns the options as a list between brackets, or blank if there are none
+func optionList(items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(items, ", ") + "]"
+}
+
+func (s *sourceBuilder) isProto3() bool {
+	return s.fd.GetSyntax() == source_ProtoSyntaxProto3
+}
+
+func (s *sourceBuilder) generate() {
+	if s.fd.GetSyntax() != "" {
+		s.P("syntax = ", strconv.Quote(s.fd.GetSyntax()), ";")
+	} else {
+		s.P("syntax = \"proto2\";")
+	}
+	if s.fd.GetPackage() != "" {
+		s.P("package ", s.fd.GetPackage(), ";")
+	}
+	s.generateOptions(s.fd.Options, options_File)
+	s.P()
+
+	public := make(map[int32]bool)
+	for _, i := range s.fd.PublicDependency {
+		public[i] = true
+	}
+	weak := make(map[int32]bool)
+	for _, i := range s.fd.WeakDependency {
+		weak[i] = true
+	}
+	for i, dep := range s.fd.Dependency {
+		switch {
+		case public[int32(i)]:
+			s.P("import public ", strconv.Quote(dep), ";")
+		case weak[int32(i)]:
+			s.P("import weak ", strconv.Quote(dep), ";")
+		default:
+			s.P("import ", strconv.Quote(dep), ";")
+		}
+	}
+	if len(s.fd.Dependency) > 0 {
+		s.P()
+	}
+
+	for i, enum := range s.fd.EnumType {
+		s.generateEnum(enum, []int32{path_File_EnumType, int32(i)})
+	}
+
+	for i, msg := range s.fd.MessageType {
+		s.generateMessage(msg, []int32{path_File_MessageType, int32(i)})
+	}
+
+	s.generateExtensions(s.fd.Extension, []int32{path_File_Extension})
+
+	for i, svc := range s.fd.Service {
+		s.generateService(svc, []int32{path_File_Service, int32(i)})
+	}
+}
+
+func (s *sourceBuilder) generateEnum(enum *descriptor.EnumDescriptorProto, path []int32) {
+	s.comment(path)
+	s.P("enum ", enum.GetName(), " {")
+	s.In()
+
+	s.generateOptions(enum.Options, options_Enum)
+
+	for i, value := range enum.Value {
+		s.comment(appendPath(path, path_Enum_Value, int32(i)))
+		s.P(value.GetName(), " = ", strconv.Itoa(int(value.GetNumber())), optionList(s.optionItems(value.Options, options_EnumValue)), ";")
+	}
+
+	s.Out()
+	s.P("}")
+	s.P()
+}
+
+func (s *sourceBuilder) generateMessage(msg *descriptor.DescriptorProto, path []int32) {
+	// map entries are generated as map fields
+	mapEntries := make(map[string]*descriptor.DescriptorProto)
+	for _, nested := range msg.NestedType {
+		if nested.Options != nil && nested.Options.GetMapEntry() {
+			mapEntries[nested.GetName()] = nested
+		}
+	}
+
+	s.comment(path)
+	s.P("message ", msg.GetName(), " {")
+	s.In()
+
+	s.generateOptions(msg.Options, options_Message)
+
+	for i, enum := range msg.EnumType {
+		s.generateEnum(enum, appendPath(path, path_Message_EnumType, int32(i)))
+	}
+
+	for i, nested := range msg.NestedType {
+		if _, ismap := mapEntries[nested.GetName()]; ismap {
+			continue
+		}
+		s.generateMessage(nested, appendPath(path, path_Message_NestedType, int32(i)))
+	}
+
+	// oneofs are generated in the position of their first field
+	oneofDone := make(map[int32]bool)
+	for i, field := range msg.Field {
+		if field.OneofIndex != nil && !field.GetProto3Optional() {
+			idx := field.GetOneofIndex()
+			if oneofDone[idx] {
+				continue
+			}
+			oneofDone[idx] = true
+
+			s.comment(appendPath(path, path_Message_OneofDecl, idx))
+			s.P("oneof ", msg.OneofDecl[idx].GetName(), " {")
+			s.In()
+			s.generateOptions(msg.OneofDecl[idx].Options, options_Oneof)
+			for j, oofield := range msg.Field {
+				if oofield.OneofIndex != nil && oofield.GetOneofIndex() == idx && !oofield.GetProto3Optional() {
+					s.generateField(oofield, appendPath(path, path_Message_Field, int32(j)), nil, true)
+				}
+			}
+			s.Out()
+			s.P("}")
+			continue
+		}
+
+		s.generateField(field, appendPath(path, path_Message_Field, int32(i)), mapEntries, false)
+	}
+
+	s.generateExtensions(msg.Extension, appendPath(path, path_Message_Extension))
+
+	for _, er := range msg.ExtensionRange {
+		// the end is exclusive on the descriptor
+		if er.GetEnd() >= 536870912 {
+			s.P("extensions ", strconv.Itoa(int(er.GetStart())), " to max;")
+		} else if er.GetStart() == er.GetEnd()-1 {
+			s.P("extensions ", strconv.Itoa(int(er.GetStart())), ";")
+		} else {
+			s.P("extensions ", strconv.Itoa(int(er.GetStart())), " to ", strconv.Itoa(int(er.GetEnd()-1)), ";")
+		}
+	}
+
+	s.Out()
+	s.P("}")
+	s.P()
+}
+
+func (s *sourceBuilder) generateExtensions(extensions []*descriptor.FieldDescriptorProto, path []int32) {
+	// group by extendee, keeping the declaration order
+	var extendees []string
+	byExtendee := make(map[string][]int)
+	for i, ext := range extensions {
+		if _, ok := byExtendee[ext.GetExtendee()]; !ok {
+			extendees = append(extendees, ext.GetExtendee())
+		}
+		byExtendee[ext.GetExtendee()] = append(byExtendee[ext.GetExtendee()], i)
+	}
+
+	for _, extendee := range extendees {
+		s.P("extend ", extendee, " {")
+		s.In()
+		for _, i := range byExtendee[extendee] {
+			s.generateField(extensions[i], appendPath(path, int32(i)), nil, false)
+		}
+		s.Out()
+		s.P("}")
+		s.P()
+	}
+}
+
+func (s *sourceBuilder) generateField(field *descriptor.FieldDescriptorProto, path []int32, mapEntries map[string]*descriptor.DescriptorProto, inOneof bool) {
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_GROUP {
+		s.fail(fmt.Errorf("Group field %s is not supported", field.GetName()))
+		return
+	}
+
+	s.comment(path)
+
+	// map<key, value>
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED && field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		typeName := field.GetTypeName()
+		if i := strings.LastIndex(typeName, "."); i >= 0 {
+			typeName = typeName[i+1:]
+		}
+		if entry, ismap := mapEntries[typeName]; ismap && len(entry.Field) == 2 {
+			s.P("map<", fieldTypeName(entry.Field[0]), ", ", fieldTypeName(entry.Field[1]), "> ", field.GetName(), " = ", strconv.Itoa(int(field.GetNumber())), s.fieldOptions(field), ";")
+			return
+		}
+	}
+
+	var label string
+	switch {
+	case inOneof:
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		label = "repeated "
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED:
+		label = "required "
+	case !s.isProto3() || field.GetProto3Optional():
+		label = "optional "
+	}
+
+	s.P(label, fieldTypeName(field), " ", field.GetName(), " = ", strconv.Itoa(int(field.GetNumber())), s.fieldOptions(field), ";")
+}
+
+func (s *sourceBuilder) fieldOptions(field *descriptor.FieldDescriptorProto) string {
+	var opts []string
+
+	if field.DefaultValue != nil {
+		switch field.GetType() {
+		case descriptor.FieldDescriptorProto_TYPE_STRING:
+			opts = append(opts, "default = \""+cEscape(field.GetDefaultValue())+"\"")
+		case descriptor.FieldDescriptorProto_TYPE_BYTES:
+			// already C-escaped by protoc
+			opts = append(opts, "default = \""+field.GetDefaultValue()+"\"")
+		default:
+			opts = append(opts, "default = "+field.GetDefaultValue())
+		}
+	}
+
+	opts = append(opts, s.optionItems(field.Options, options_Field)...)
+
+	return optionList(opts)
+}
+
+// Returns the proto type of the field. Message and enum types are kept fully qualified (".package.Type"), so they
+// are not resolved relative to the current scope.
+func fieldTypeName(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return field.GetTypeName()
+	}
+	return strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_"))
+}
+
+// Escapes a string default value as protoc does for bytes: quotes, backslashes and control characters with C escapes,
+// and non-ASCII bytes as octal.
+func cEscape(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch c {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '\\':
+			b.WriteString(`\\`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.String()
+}
+
+func (s *sourceBuilder) generateService(svc *descriptor.ServiceDescriptorProto, path []int32) {
+	s.comment(path)
+	s.P("service ", svc.GetName(), " {")
+	s.In()
+
+	s.generateOptions(svc.Options, options_Service)
+
+	for i, method := range svc.Method {
+		var streamReq, streamResp string
+		if method.GetClientStreaming() {
+			streamReq = "stream "
+		}
+		if method.GetServerStreaming() {
+			streamResp = "stream "
+		}
+
+		s.comment(appendPath(path, path_Service_Method, int32(i)))
+		rpc := fmt.Sprintf("rpc %s(%s%s) returns (%s%s)", method.GetName(), streamReq, method.GetInputType(),
+			streamResp, method.GetOutputType())
+
+		items := s.optionItems(method.Options, options_Method)
+		if len(items) == 0 {
+			s.P(rpc, ";")
+			continue
+		}
+		s.P(rpc, " {")
+		s.In()
+		for _, item := range items {
+			s.P("option ", item, ";")
+		}
+		s.Out()
+		s.P("}")
+	}
+
+	s.Out()
+	s.P("}")
+	s.P()
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Returns the wire format of a field
+func testWireField(number int32, wireType int, value []byte) []byte {
+	ret := proto.EncodeVarint(uint64(number)<<3 | uint64(wireType))
+	if wireType == wire_Bytes {
+		ret = append(ret, proto.EncodeVarint(uint64(len(value)))...)
+	}
+	return append(ret, value...)
+}
+
+// Unmarshals the options from the wire format, keeping the custom options unknown, as they are received from protoc
+func testOptions(t *testing.T, opts proto.Message, fields ...[]byte) {
+	var data []byte
+	for _, f := range fields {
+		data = append(data, f...)
+	}
+	if err := proto.Unmarshal(data, opts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Options file, declaring the custom options
+func testOptionsFile() *descriptor.FileDescriptorProto {
+	return &descriptor.FileDescriptorProto{
+		Name:       proto.String("options.proto"),
+		Package:    proto.String("opt"),
+		Dependency: []string{"google/protobuf/descriptor.proto"},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("Rule"),
+				Field: []*descriptor.FieldDescriptorProto{
+					{Name: proto.String("name"), Number: proto.Int32(1), Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum()},
+					{Name: proto.String("min"), Number: proto.Int32(2), Type: descriptor.FieldDescriptorProto_TYPE_SINT32.Enum()},
+				},
+			},
+		},
+		Extension: []*descriptor.FieldDescriptorProto{
+			{
+				Name: proto.String("wrap"), Number: proto.Int32(50000), Extendee: proto.String(options_File),
+				Type: descriptor.FieldDescriptorProto_TYPE_BOOL.Enum(),
+			},
+			{
+				Name: proto.String("rule"), Number: proto.Int32(50001), Extendee: proto.String(options_Field),
+				Type: descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".opt.Rule"),
+			},
+			{
+				Name: proto.String("tags"), Number: proto.Int32(50002), Extendee: proto.String(options_Message),
+				Type: descriptor.FieldDescriptorProto_TYPE_INT32.Enum(), Label: descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+			},
+		},
+	}
+}
+
+func TestBuildSourceOptions(t *testing.T) {
+	fileOptions := &descriptor.FileOptions{}
+	testOptions(t, fileOptions,
+		testWireField(11, wire_Bytes, []byte("example.com/app")),
+		testWireField(50000, wire_Varint, []byte{0}),
+	)
+	fieldOptions := &descriptor.FieldOptions{}
+	testOptions(t, fieldOptions,
+		testWireField(3, wire_Varint, []byte{1}),
+		testWireField(50001, wire_Bytes, append(
+			testWireField(1, wire_Bytes, []byte("size \"x\"")),
+			testWireField(2, wire_Varint, []byte{3})...)),
+	)
+	messageOptions := &descriptor.MessageOptions{}
+	testOptions(t, messageOptions,
+		testWireField(50002, wire_Bytes, []byte{1, 2}),
+	)
+
+	fd := &descriptor.FileDescriptorProto{
+		Name:       proto.String("app.proto"),
+		Package:    proto.String("app"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"options.proto"},
+		Options:    fileOptions,
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name:    proto.String("Item"),
+				Options: messageOptions,
+				Field: []*descriptor.FieldDescriptorProto{
+					{
+						Name: proto.String("name"), Number: proto.Int32(1), Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+						Label: descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Options: fieldOptions,
+					},
+				},
+			},
+		},
+		EnumType: []*descriptor.EnumDescriptorProto{
+			{
+				Name:    proto.String("Kind"),
+				Options: &descriptor.EnumOptions{AllowAlias: proto.Bool(true)},
+				Value: []*descriptor.EnumValueDescriptorProto{
+					{Name: proto.String("KIND_A"), Number: proto.Int32(0)},
+					{Name: proto.String("KIND_B"), Number: proto.Int32(0), Options: &descriptor.EnumValueOptions{Deprecated: proto.Bool(true)}},
+				},
+			},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{
+			{
+				Name: proto.String("ItemSvc"),
+				Method: []*descriptor.MethodDescriptorProto{
+					{
+						Name: proto.String("Get"), InputType: proto.String(".app.Item"), OutputType: proto.String(".app.Item"),
+						Options: &descriptor.MethodOptions{Deprecated: proto.Bool(true)},
+					},
+				},
+			},
+		},
+	}
+
+	src, err := buildSource(fd, newOptionRenderer([]*descriptor.FileDescriptorProto{testOptionsFile(), fd}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `syntax = "proto3";
+package app;
+option go_package = "example.com/app";
+option (opt.wrap) = false;
+
+import "options.proto";
+
+enum Kind {
+    option allow_alias = true;
+    KIND_A = 0;
+    KIND_B = 0 [deprecated = true];
+}
+
+message Item {
+    option (opt.tags) = 1;
+    option (opt.tags) = 2;
+    string name = 1 [deprecated = true, (opt.rule) = { name: "size \"x\"" min: -2 }];
+}
+
+service ItemSvc {
+    rpc Get(.app.Item) returns (.app.Item) {
+        option deprecated = true;
+    }
+}
+
+`
+	if string(src) != expected {
+		t.Errorf("unexpected source:\n%s\nexpected:\n%s", src, expected)
+	}
+}
+
+func TestBuildSourceGroup(t *testing.T) {
+	fd := &descriptor.FileDescriptorProto{
+		Name: proto.String("group.proto"),
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("Item"),
+				Field: []*descriptor.FieldDescriptorProto{
+					{
+						Name: proto.String("result"), Number: proto.Int32(1), Type: descriptor.FieldDescriptorProto_TYPE_GROUP.Enum(),
+						Label: descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), TypeName: proto.String(".Item.Result"),
+					},
+				},
+			},
+		},
+	}
+
+	_, err := buildSource(fd, newOptionRenderer([]*descriptor.FileDescriptorProto{fd}))
+	if err == nil {
+		t.Fatal("expected a group error")
+	}
+	if !strings.Contains(err.Error(), "Group field result is not supported") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -2,6 +2,8 @@ package fproto_gowrap
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/RangelReale/fproto"
@@ -19,6 +21,21 @@ func NewServiceGen_gRPC() *ServiceGen_gRPC {
 	}
 }
 
+func init() {
+	// options: wrap_errors=true|false
+	RegisterServiceGen("grpc", func(options map[string]string) (ServiceGen, error) {
+		ret := NewServiceGen_gRPC()
+		if v, ok := options["wrap_errors"]; ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid wrap_errors option: %v", err)
+			}
+			ret.WrapErrors = b
+		}
+		return ret, nil
+	})
+}
+
 func (s *ServiceGen_gRPC) ServiceType() string {
 	return "grpc"
 }
@@ -0,0 +1,274 @@
+package fproto_gowrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/RangelReale/fproto"
+)
+
+// Side-car source map of a generated file, linking its line ranges to the proto elements they were generated from.
+type SourceMap struct {
+	// Generated marker, so the source map is handled like the generated files
+	Generated string `json:"generated"`
+	// Generated file name
+	Filename string `json:"filename"`
+	// Element regions, in start line order. Regions can be nested, like fields inside a message.
+	Entries []*SourceMapEntry `json:"entries"`
+}
+
+// A region of a generated file
+type SourceMapEntry struct {
+	// Lines of the generated file, starting at 1, inclusive
+	StartLine int `json:"start_line"`
+	EndLine   int `json:"end_line"`
+	// Proto file path
+	File string `json:"file"`
+	// Path of the element on the proto file, like "Message.field"
+	Element string `json:"element"`
+	// Position of the element on the proto file, if known
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+}
+
+// Returns the source map position of the element, "file:line:col"
+func (e *SourceMapEntry) Position() string {
+	ret := e.File
+	if e.Line > 0 {
+		ret += fmt.Sprintf(":%d", e.Line)
+		if e.Column > 0 {
+			ret += fmt.Sprintf(":%d", e.Column)
+		}
+	}
+	return ret
+}
+
+// Returns the innermost entry containing the generated line, or nil if none
+func (s *SourceMap) Find(line int) *SourceMapEntry {
+	var ret *SourceMapEntry
+	for _, e := range s.Entries {
+		if line >= e.StartLine && line <= e.EndLine {
+			ret = e
+		}
+	}
+	return ret
+}
+
+// Starts a region of code generated from the element. Must be closed with EndElement. Does nothing if neither
+// SourceMap nor PositionComments are enabled.
+func (g *GeneratorFile) BeginElement(element fproto.FProtoElement) {
+	if !g.SourceMap && !g.PositionComments {
+		return
+	}
+
+	e := &SourceMapEntry{
+		Element: g.generator.elementPath(element),
+	}
+	if g.generator.depfile != nil {
+		e.File = g.generator.depfile.FilePath
+	}
+	e.Line, e.Column = g.generator.elementPosition(e.File, e.Element)
+
+	// position comments only for the top level elements
+	_, isfield := element.(fproto.FieldElementTag)
+
+	g.elements = append(g.elements, &sourceMapElement{entry: e, comment: g.PositionComments && !isfield, start: g.Len(), end: -1})
+	g.openElements = append(g.openElements, len(g.elements)-1)
+}
+
+// Ends the last region started with BeginElement
+func (g *GeneratorFile) EndElement() {
+	if len(g.openElements) == 0 {
+		return
+	}
+	g.elements[g.openElements[len(g.openElements)-1]].end = g.Len()
+	g.openElements = g.openElements[:len(g.openElements)-1]
+}
+
+// Returns the source map of the formatted file, or nil if SourceMap is not enabled
+func (g *GeneratorFile) GetSourceMap() (*SourceMap, error) {
+	if !g.SourceMap {
+		return nil, nil
+	}
+
+	_, err := g.Format()
+	if err != nil {
+		return nil, err
+	}
+	return g.sourceMap, nil
+}
+
+// Returns the expected source map file path and name
+func (g *GeneratorFile) SourceMapFilename() string {
+	return strings.TrimSuffix(g.Filename(), ".go") + ".map.json"
+}
+
+// Writes the source map as JSON. The SourceMap option must be enabled.
+func (g *GeneratorFile) OutputSourceMap(w io.Writer) error {
+	sm, err := g.GetSourceMap()
+	if err != nil {
+		return err
+	}
+	if sm == nil {
+		return fmt.Errorf("Source map is not enabled for the file %s", g.Filename())
+	}
+
+	b, err := json.MarshalIndent(sm, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// A region started with BeginElement, with its offsets on the unformatted file body
+type sourceMapElement struct {
+	entry   *SourceMapEntry
+	comment bool
+	start   int
+	end     int
+}
+
+// A token of a Go source. Semicolons are not included, as the formatting can add or remove them.
+type sourceMapToken struct {
+	tok    token.Token
+	offset int
+	line   int
+	column int
+	// number of lines of the token
+	lines int
+}
+
+// Returns the tokens of the Go source, including the comments
+func sourceMapTokens(src []byte) []sourceMapToken {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+
+	var ret []sourceMapToken
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON {
+			continue
+		}
+		p := fset.Position(pos)
+		ret = append(ret, sourceMapToken{
+			tok:    tok,
+			offset: p.Offset,
+			line:   p.Line,
+			column: p.Column,
+			lines:  strings.Count(lit, "\n") + 1,
+		})
+	}
+	return ret
+}
+
+// Builds the source map and adds the position comments to the formatted source. The regions are located on the
+// formatted source by matching the tokens of the unformatted one, whose body starts at bodyOffset, as the
+// formatting only changes the whitespace between them.
+func (g *GeneratorFile) applySourceMap(raw []byte, bodyOffset int, formatted []byte) ([]byte, error) {
+	if !g.SourceMap && !g.PositionComments {
+		return formatted, nil
+	}
+
+	rawTokens := sourceMapTokens(raw)
+	fmtTokens := sourceMapTokens(formatted)
+	if len(rawTokens) != len(fmtTokens) {
+		return nil, g.generator.fileError(fmt.Errorf("source map of %s could not be built: the formatted source has different tokens", g.Filename()))
+	}
+	for i := range rawTokens {
+		if rawTokens[i].tok != fmtTokens[i].tok {
+			return nil, g.generator.fileError(fmt.Errorf("source map of %s could not be built: the formatted source has different tokens", g.Filename()))
+		}
+	}
+
+	// locate the regions with code on the formatted source
+	var entries []*SourceMapEntry
+	var comments []*sourceMapElement
+	for _, el := range g.elements {
+		end := el.end
+		if end < 0 {
+			end = el.start
+		}
+		first := sort.Search(len(rawTokens), func(i int) bool { return rawTokens[i].offset >= bodyOffset+el.start })
+		last := sort.Search(len(rawTokens), func(i int) bool { return rawTokens[i].offset >= bodyOffset+end }) - 1
+		if first > last {
+			continue
+		}
+
+		el.entry.StartLine = fmtTokens[first].line
+		el.entry.EndLine = fmtTokens[last].line + fmtTokens[last].lines - 1
+		entries = append(entries, el.entry)
+
+		// only at the top level, where the comment lines don't change the formatting
+		if el.comment && fmtTokens[first].column == 1 {
+			comments = append(comments, el)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].StartLine < entries[j].StartLine })
+
+	out := formatted
+	if len(comments) > 0 {
+		out = g.addPositionComments(formatted, comments, entries)
+	}
+
+	if g.SourceMap {
+		g.sourceMap = &SourceMap{
+			Generated: GENERATED_MARKER + ". DO NOT EDIT.",
+			Filename:  g.Filename(),
+			Entries:   entries,
+		}
+	}
+
+	return out, nil
+}
+
+// Adds the position comments before the start line of the elements, separated by blank lines, and moves the
+// entries after them.
+func (g *GeneratorFile) addPositionComments(src []byte, comments []*sourceMapElement, entries []*SourceMapEntry) []byte {
+	lines := bytes.Split(src, []byte("\n"))
+
+	// added lines before each line of the formatted source, starting at 1
+	added := make([][][]byte, len(lines)+1)
+	for _, el := range comments {
+		line := el.entry.StartLine
+		if len(added[line]) > 0 {
+			continue
+		}
+		if line > 1 && len(bytes.TrimSpace(lines[line-2])) > 0 {
+			added[line] = append(added[line], nil)
+		}
+		added[line] = append(added[line], []byte(strings.TrimSpace("// proto source: "+el.entry.Position()+" "+el.entry.Element)), nil)
+	}
+
+	// new line number of each line
+	shift := make([]int, len(lines)+1)
+	n := 0
+	for line := 1; line <= len(lines); line++ {
+		n += len(added[line])
+		shift[line] = n
+	}
+	for _, e := range entries {
+		e.StartLine += shift[e.StartLine]
+		e.EndLine += shift[e.EndLine]
+	}
+
+	var out [][]byte
+	for i, line := range lines {
+		out = append(out, added[i+1]...)
+		out = append(out, line)
+	}
+	return bytes.Join(out, []byte("\n"))
+}
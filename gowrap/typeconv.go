@@ -21,3 +21,36 @@ type TypeConverter interface {
 	// Generates code to export the type to the Go protobuf generated type
 	GenerateExport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error)
 }
+
+// Optional TypeConverter interface, for converters whose Go type can tell an unset value from a zero value.
+// Without it, only fields with a nillable Go type (pointers, slices and maps) have presence helpers generated.
+type TypeConverter_Presence interface {
+	// Returns a boolean Go expression that is true if the value is set
+	GeneratePresence(g *GeneratorFile, varSrc string) (string, error)
+
+	// Generates code to unset the value
+	GenerateClear(g *GeneratorFile, varDest string) error
+}
+
+// Optional TypeConverter interface, for converters that can't convert pointers to values.
+// On fields whose source type is a pointer to a scalar or enum (proto2 singular fields and proto3 optional fields),
+// the generator converts the pointed value instead, and keeps nil values unset.
+type TypeConverter_Value interface {
+	// Returns true if the converter can only convert values
+	ValueOnly() bool
+}
+
+// Optional TypeConverter interface, for converters whose Go type can be converted further by another converter,
+// like a google.protobuf.Timestamp converter to time.Time followed by a time.Time converter to an application type.
+type TypeConverter_Intermediate interface {
+	// Returns an identifier of the Go type of the converter, like "time.Time", or blank to end the chain
+	IntermediateType() string
+}
+
+// Optional TypeConverterPlugin interface, for plugins that convert the intermediate type of another converter.
+// The generator chains the Import and Export code of all the converters, checking the errors at each stage.
+type TypeConverterPlugin_Chain interface {
+	// Returns a type converter from the intermediate type, or nil. Its Import code receives the intermediate type,
+	// and its Export code must output it. tp is the original proto type.
+	GetChainTypeConverter(intermediateType string, tp *fdep.DepType) TypeConverter
+}
@@ -0,0 +1,152 @@
+package fproto_gowrap
+
+import (
+	"strconv"
+
+	"github.com/RangelReale/fdep"
+	"github.com/RangelReale/fproto"
+)
+
+// Proto file of the Any type
+const ANY_FILEPATH = "google/protobuf/any.proto"
+
+//
+// TypeConverterPlugin: Any
+//
+
+// Type converter plugin for google.protobuf.Any, converted to an interface{} holding the wrapped struct of the
+// packed message, like *MyMessage. The wrapped message types must be registered on the gowrap util package, which
+// the "any_registry" customizer generates for each wrapped file.
+type TypeConverterPlugin_Any struct {
+}
+
+func NewTypeConverterPlugin_Any() *TypeConverterPlugin_Any {
+	return &TypeConverterPlugin_Any{}
+}
+
+func init() {
+	RegisterTypeConverterPlugin("any", func(options map[string]string) (TypeConverterPlugin, error) {
+		return NewTypeConverterPlugin_Any(), nil
+	})
+	RegisterCustomizer("any_registry", func(options map[string]string) (Customizer, error) {
+		return &Customizer_AnyRegistry{}, nil
+	})
+}
+
+func (t *TypeConverterPlugin_Any) GetTypeConverter(tp *fdep.DepType) TypeConverter {
+	if tp.DepFile == nil || tp.DepFile.FilePath != ANY_FILEPATH || tp.Name != "Any" {
+		return nil
+	}
+
+	return &TypeConverter_Any{
+		tp: tp,
+	}
+}
+
+//
+// TypeConverter: Any
+//
+
+const (
+	TCID_ANY TCID = "950224e3-1f4d-47f4-ae67-e8daeb1ddba3"
+)
+
+// Type converter for google.protobuf.Any
+type TypeConverter_Any struct {
+	tp *fdep.DepType
+}
+
+func (t *TypeConverter_Any) TCID() TCID {
+	return TCID_ANY
+}
+
+func (t *TypeConverter_Any) TypeName(g *GeneratorFile, tntype TypeNameType, options uint32) string {
+	switch tntype {
+	case TNT_EMPTYVALUE, TNT_EMPTYORNILVALUE:
+		return "nil"
+	}
+	return "interface{}"
+}
+
+func (t *TypeConverter_Any) IsPointer() bool {
+	return false
+}
+
+func (t *TypeConverter_Any) GenerateImport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	util_alias := g.DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util")
+
+	// varDest, err = fproto_gowrap_util.ImportAny(varSrc)
+	g.P(varDest, ", ", varError, " = ", util_alias, ".ImportAny(", varSrc, ")")
+	return true, nil
+}
+
+func (t *TypeConverter_Any) GenerateExport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	util_alias := g.DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util")
+
+	// varDest, err = fproto_gowrap_util.ExportAny(varSrc)
+	g.P(varDest, ", ", varError, " = ", util_alias, ".ExportAny(", varSrc, ")")
+	return true, nil
+}
+
+//
+// Customizer: AnyRegistry
+//
+
+// Customizer that registers the wrapped messages of each file on the gowrap util package, so they can be
+// converted from and to google.protobuf.Any.
+type Customizer_AnyRegistry struct {
+}
+
+func (c *Customizer_AnyRegistry) GenerateCode(g *Generator) error {
+	var messages []*fproto.MessageElement
+	for _, m := range g.GetDepFile().ProtoFile.CollectMessages() {
+		message := m.(*fproto.MessageElement)
+		if message.IsExtend {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	util_alias := g.FImpExp().DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util")
+	proto_alias := g.FImpExp().DeclDep("github.com/golang/protobuf/proto", "proto")
+	go_alias_ie := g.FImpExp().DeclFileDep(nil, "", false)
+
+	// func init() {
+	g.FImpExp().P("func init() {")
+	g.FImpExp().In()
+
+	for _, message := range messages {
+		msgGoName, _ := g.BuildMessageName(message)
+
+		// fproto_gowrap_util.RegisterAnyType(&fproto_gowrap_util.AnyType{
+		g.FImpExp().P(util_alias, ".RegisterAnyType(&", util_alias, ".AnyType{")
+		g.FImpExp().In()
+		g.FImpExp().P("Name: ", strconv.Quote(g.BuildMessageFullName(message)), ",")
+		g.FImpExp().P("Value: (*", msgGoName, ")(nil),")
+		g.FImpExp().P("Import: func(s ", proto_alias, ".Message) (interface{}, error) {")
+		g.FImpExp().In()
+		g.FImpExp().P("return ", g.wrapName(g.GetDepFile(), "", msgGoName+"_Import"), "(s.(*", go_alias_ie, ".", msgGoName, "))")
+		g.FImpExp().Out()
+		g.FImpExp().P("},")
+		g.FImpExp().P("Export: func(v interface{}) (", proto_alias, ".Message, error) {")
+		g.FImpExp().In()
+		g.FImpExp().P("return v.(*", msgGoName, ").", g.wrapName(g.GetDepFile(), msgGoName, "Export"), "()")
+		g.FImpExp().Out()
+		g.FImpExp().P("},")
+		g.FImpExp().Out()
+		g.FImpExp().P("})")
+	}
+
+	g.FImpExp().Out()
+	g.FImpExp().P("}")
+	g.FImpExp().P()
+
+	return nil
+}
+
+func (c *Customizer_AnyRegistry) GenerateServiceCode(g *Generator) error {
+	return nil
+}
@@ -0,0 +1,153 @@
+package fproto_gowrap
+
+import "strconv"
+
+//
+// TypeConverter: Chain
+//
+
+// Type converter that chains the Import and Export code of converters through their intermediate types.
+// The first stage converts from the proto type, and the Go type is the one of the last stage.
+type TypeConverter_Chain struct {
+	Stages []TypeConverter
+}
+
+// Creates a chain of the converters. Returns the converter itself if only one, and implements TypeConverter_Presence
+// if the last stage does.
+func NewTypeConverter_Chain(stages []TypeConverter) TypeConverter {
+	if len(stages) == 1 {
+		return stages[0]
+	}
+
+	ret := &TypeConverter_Chain{
+		Stages: stages,
+	}
+	if _, ok := stages[len(stages)-1].(TypeConverter_Presence); ok {
+		return &TypeConverter_ChainPresence{ret}
+	}
+	return ret
+}
+
+func (t *TypeConverter_Chain) TCID() TCID {
+	return t.last().TCID()
+}
+
+func (t *TypeConverter_Chain) TypeName(g *GeneratorFile, tntype TypeNameType, options uint32) string {
+	return t.last().TypeName(g, tntype, options)
+}
+
+func (t *TypeConverter_Chain) IsPointer() bool {
+	return t.last().IsPointer()
+}
+
+// The source type is converted by the first stage
+func (t *TypeConverter_Chain) ValueOnly() bool {
+	return isValueOnly(t.Stages[0])
+}
+
+func (t *TypeConverter_Chain) GenerateImport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	// {
+	// 	var chainValue1 time.Time
+	// 	chainValue1, err = ...(varSrc)
+	// 	if err == nil {
+	// 		varDest, err = ...(chainValue1)
+	// 	}
+	// }
+	g.P("{")
+	g.In()
+
+	src := varSrc
+	opened := 0
+	for i, tc := range t.Stages {
+		dest := varDest
+		if i < len(t.Stages)-1 {
+			dest = "chainValue" + strconv.Itoa(i+1)
+			g.P("var ", dest, " ", tc.TypeName(g, TNT_TYPENAME, 0))
+		}
+
+		check, err := tc.GenerateImport(g, src, dest, varError)
+		if err != nil {
+			return false, err
+		}
+		if check {
+			checkError = true
+			if i < len(t.Stages)-1 {
+				// the next stages only run if this one succeeded
+				g.P("if ", varError, " == nil {")
+				g.In()
+				opened++
+			}
+		}
+
+		src = dest
+	}
+
+	for ; opened > 0; opened-- {
+		g.Out()
+		g.P("}")
+	}
+
+	g.Out()
+	g.P("}")
+
+	return checkError, nil
+}
+
+func (t *TypeConverter_Chain) GenerateExport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	// the stages are exported in reverse order
+	g.P("{")
+	g.In()
+
+	src := varSrc
+	opened := 0
+	for i := len(t.Stages) - 1; i >= 0; i-- {
+		dest := varDest
+		if i > 0 {
+			dest = "chainValue" + strconv.Itoa(i)
+			g.P("var ", dest, " ", t.Stages[i-1].TypeName(g, TNT_TYPENAME, 0))
+		}
+
+		check, err := t.Stages[i].GenerateExport(g, src, dest, varError)
+		if err != nil {
+			return false, err
+		}
+		if check {
+			checkError = true
+			if i > 0 {
+				// the next stages only run if this one succeeded
+				g.P("if ", varError, " == nil {")
+				g.In()
+				opened++
+			}
+		}
+
+		src = dest
+	}
+
+	for ; opened > 0; opened-- {
+		g.Out()
+		g.P("}")
+	}
+
+	g.Out()
+	g.P("}")
+
+	return checkError, nil
+}
+
+func (t *TypeConverter_Chain) last() TypeConverter {
+	return t.Stages[len(t.Stages)-1]
+}
+
+// Chain whose last stage implements TypeConverter_Presence
+type TypeConverter_ChainPresence struct {
+	*TypeConverter_Chain
+}
+
+func (t *TypeConverter_ChainPresence) GeneratePresence(g *GeneratorFile, varSrc string) (string, error) {
+	return t.last().(TypeConverter_Presence).GeneratePresence(g, varSrc)
+}
+
+func (t *TypeConverter_ChainPresence) GenerateClear(g *GeneratorFile, varDest string) error {
+	return t.last().(TypeConverter_Presence).GenerateClear(g, varDest)
+}
@@ -0,0 +1,183 @@
+package fproto_gowrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/RangelReale/fdep"
+)
+
+// Converter stage that converts with a "From<Name>" / "To<Name>" function
+type testChainConverter struct {
+	name         string
+	goType       string
+	intermediate string
+	checkError   bool
+}
+
+func (t *testChainConverter) TCID() TCID {
+	return TCID("test-" + t.name)
+}
+
+func (t *testChainConverter) TypeName(g *GeneratorFile, tntype TypeNameType, options uint32) string {
+	return t.goType
+}
+
+func (t *testChainConverter) IsPointer() bool {
+	return false
+}
+
+func (t *testChainConverter) GenerateImport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	if t.checkError {
+		g.P(varDest, ", ", varError, " = From", t.name, "(", varSrc, ")")
+	} else {
+		g.P(varDest, " = From", t.name, "(", varSrc, ")")
+	}
+	return t.checkError, nil
+}
+
+func (t *testChainConverter) GenerateExport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	if t.checkError {
+		g.P(varDest, ", ", varError, " = To", t.name, "(", varSrc, ")")
+	} else {
+		g.P(varDest, " = To", t.name, "(", varSrc, ")")
+	}
+	return t.checkError, nil
+}
+
+func (t *testChainConverter) IntermediateType() string {
+	return t.intermediate
+}
+
+// Plugin that converts the proto type with the first converter, and the intermediate types with the chain ones
+type testChainPlugin struct {
+	first *testChainConverter
+	chain map[string]*testChainConverter
+}
+
+func (t *testChainPlugin) GetTypeConverter(tp *fdep.DepType) TypeConverter {
+	return t.first
+}
+
+func (t *testChainPlugin) GetChainTypeConverter(intermediateType string, tp *fdep.DepType) TypeConverter {
+	if tc, ok := t.chain[intermediateType]; ok {
+		return tc
+	}
+	return nil
+}
+
+func newTestChainGenerator(t *testing.T, plugin *testChainPlugin) *Generator {
+	g, err := NewGenerator(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.TypeConverters = []TypeConverterPlugin{plugin}
+	return g
+}
+
+func testChainDepType() *fdep.DepType {
+	return &fdep.DepType{
+		DepFile:       &fdep.DepFile{FilePath: "core/event.proto"},
+		OriginalAlias: "core",
+		Name:          "Event",
+	}
+}
+
+func TestTypeConverterChain(t *testing.T) {
+	g := newTestChainGenerator(t, &testChainPlugin{
+		first: &testChainConverter{name: "Proto", goType: "time.Time", intermediate: "time.Time", checkError: true},
+		chain: map[string]*testChainConverter{
+			"time.Time": {name: "Time", goType: "app.Date", checkError: true},
+		},
+	})
+
+	tc, err := g.GetTypeConverter(testChainDepType())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tc.(*TypeConverter_Chain); !ok {
+		t.Fatalf("expected a chain converter, got %T", tc)
+	}
+
+	gf := NewGeneratorFileFixed(g, "test", "test.go")
+	if name := tc.TypeName(gf, TNT_TYPENAME, 0); name != "app.Date" {
+		t.Errorf("expected the type of the last stage, got %s", name)
+	}
+
+	check, err := tc.GenerateImport(gf, "src", "dest", "err")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check {
+		t.Error("expected the import to check the error")
+	}
+	expected := `{
+	var chainValue1 time.Time
+	chainValue1, err = FromProto(src)
+	if err == nil {
+		dest, err = FromTime(chainValue1)
+	}
+}
+`
+	if gf.String() != expected {
+		t.Errorf("unexpected import code:\n%s\nexpected:\n%s", gf.String(), expected)
+	}
+
+	gf.Reset()
+	check, err = tc.GenerateExport(gf, "src", "dest", "err")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check {
+		t.Error("expected the export to check the error")
+	}
+	expected = `{
+	var chainValue1 time.Time
+	chainValue1, err = ToTime(src)
+	if err == nil {
+		dest, err = ToProto(chainValue1)
+	}
+}
+`
+	if gf.String() != expected {
+		t.Errorf("unexpected export code:\n%s\nexpected:\n%s", gf.String(), expected)
+	}
+}
+
+func TestTypeConverterChainCycle(t *testing.T) {
+	g := newTestChainGenerator(t, &testChainPlugin{
+		first: &testChainConverter{name: "Proto", goType: "a.A", intermediate: "a.A"},
+		chain: map[string]*testChainConverter{
+			"a.A": {name: "A", goType: "b.B", intermediate: "b.B"},
+			"b.B": {name: "B", goType: "a.A", intermediate: "a.A"},
+		},
+	})
+
+	_, err := g.GetTypeConverter(testChainDepType())
+	if err == nil {
+		t.Fatal("expected a chain cycle error")
+	}
+	if !strings.Contains(err.Error(), "cycle") || !strings.HasSuffix(err.Error(), "a.A -> b.B -> a.A") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTypeInfoChainCycle(t *testing.T) {
+	g := newTestChainGenerator(t, &testChainPlugin{
+		first: &testChainConverter{name: "Proto", goType: "a.A", intermediate: "a.A"},
+		chain: map[string]*testChainConverter{
+			"a.A": {name: "A", goType: "a.A", intermediate: "a.A"},
+		},
+	})
+
+	tinfo, err := g.GetTypeInfo(testChainDepType())
+	if err == nil {
+		t.Fatal("expected a chain cycle error")
+	}
+	if tinfo != nil {
+		t.Errorf("expected no type info on error, got %v", tinfo)
+	}
+	if !strings.HasSuffix(err.Error(), "a.A -> a.A") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
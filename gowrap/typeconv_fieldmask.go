@@ -0,0 +1,437 @@
+package fproto_gowrap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/RangelReale/fdep"
+	"github.com/RangelReale/fproto"
+)
+
+// Proto file of the FieldMask type
+const FIELDMASK_FILEPATH = "google/protobuf/field_mask.proto"
+
+// Go type of the FieldMask type
+type FieldMaskFormat int
+
+const (
+	// Slice of the paths ([]string)
+	FIELDMASKFORMAT_SLICE FieldMaskFormat = iota
+	// Path set of the gowrap util package (fproto_gowrap_util.FieldPaths)
+	FIELDMASKFORMAT_PATHS
+)
+
+// Parses a field mask format name: "slice" (or blank) or "paths"
+func ParseFieldMaskFormat(name string) (FieldMaskFormat, error) {
+	switch name {
+	case "", "slice":
+		return FIELDMASKFORMAT_SLICE, nil
+	case "paths":
+		return FIELDMASKFORMAT_PATHS, nil
+	}
+	return FIELDMASKFORMAT_SLICE, fmt.Errorf("Invalid field mask format: %s", name)
+}
+
+//
+// TypeConverterPlugin: FieldMask
+//
+
+// Type converter plugin for google.protobuf.FieldMask, converted to its paths, using the conversion functions of the
+// gowrap util package. The "fieldmask_helpers" customizer generates the helpers to validate and apply the paths on
+// the wrapped structs.
+type TypeConverterPlugin_FieldMask struct {
+	Format FieldMaskFormat
+}
+
+func NewTypeConverterPlugin_FieldMask() *TypeConverterPlugin_FieldMask {
+	return &TypeConverterPlugin_FieldMask{
+		Format: FIELDMASKFORMAT_SLICE,
+	}
+}
+
+func init() {
+	// options: format=slice|paths
+	RegisterTypeConverterPlugin("fieldmask", func(options map[string]string) (TypeConverterPlugin, error) {
+		ret := NewTypeConverterPlugin_FieldMask()
+		format, err := ParseFieldMaskFormat(options["format"])
+		if err != nil {
+			return nil, err
+		}
+		ret.Format = format
+		return ret, nil
+	})
+	RegisterCustomizer("fieldmask_helpers", func(options map[string]string) (Customizer, error) {
+		return &Customizer_FieldMask{}, nil
+	})
+}
+
+func (t *TypeConverterPlugin_FieldMask) GetTypeConverter(tp *fdep.DepType) TypeConverter {
+	if tp.DepFile == nil || tp.DepFile.FilePath != FIELDMASK_FILEPATH || tp.Name != "FieldMask" {
+		return nil
+	}
+
+	return &TypeConverter_FieldMask{
+		tp:     tp,
+		format: t.Format,
+	}
+}
+
+//
+// TypeConverter: FieldMask
+//
+
+const (
+	TCID_FIELDMASK TCID = "5ebf8507-8d87-45c3-b346-bcb9d1250bdf"
+)
+
+// Type converter for google.protobuf.FieldMask
+type TypeConverter_FieldMask struct {
+	tp     *fdep.DepType
+	format FieldMaskFormat
+}
+
+func (t *TypeConverter_FieldMask) TCID() TCID {
+	return TCID_FIELDMASK
+}
+
+func (t *TypeConverter_FieldMask) TypeName(g *GeneratorFile, tntype TypeNameType, options uint32) string {
+	goType := "[]string"
+	if t.format == FIELDMASKFORMAT_PATHS {
+		goType = g.DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util") + ".FieldPaths"
+	}
+
+	switch tntype {
+	case TNT_EMPTYVALUE:
+		return goType + "{}"
+	case TNT_EMPTYORNILVALUE:
+		return "nil"
+	}
+	return goType
+}
+
+func (t *TypeConverter_FieldMask) IsPointer() bool {
+	return false
+}
+
+func (t *TypeConverter_FieldMask) GenerateImport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	util_alias := g.DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util")
+
+	// varDest = fproto_gowrap_util.ImportFieldMask(varSrc)
+	g.P(varDest, " = ", util_alias, ".Import", t.utilSuffix(), "(", varSrc, ")")
+	return false, nil
+}
+
+func (t *TypeConverter_FieldMask) GenerateExport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	util_alias := g.DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util")
+
+	// varDest = fproto_gowrap_util.ExportFieldMask(varSrc)
+	g.P(varDest, " = ", util_alias, ".Export", t.utilSuffix(), "(", varSrc, ")")
+	return false, nil
+}
+
+// Suffix of the conversion functions of the gowrap util package
+func (t *TypeConverter_FieldMask) utilSuffix() string {
+	if t.format == FIELDMASKFORMAT_PATHS {
+		return "FieldPaths"
+	}
+	return "FieldMask"
+}
+
+//
+// Customizer: FieldMask
+//
+
+// Customizer that generates field mask helpers for each wrapped message:
+//
+//	func MyMessage_ValidateFieldMask(paths []string) error
+//	func (m *MyMessage) ApplyFieldMask(src *MyMessage, paths []string) error
+//
+// The paths use the proto field names. Sub paths are allowed on singular wrapped message fields, whose packages must
+// also be generated with this customizer.
+type Customizer_FieldMask struct {
+}
+
+// A field of a message that can be selected by a field mask
+type fieldMaskField struct {
+	protoName string
+	// Go name of the struct field
+	goName string
+	// Go name of the oneof member struct, if the field is in a oneof
+	oneofGoName string
+	// Wrapped message type, if sub paths are allowed
+	tp *fdep.DepType
+}
+
+func (c *Customizer_FieldMask) GenerateCode(g *Generator) error {
+	for _, m := range g.GetDepFile().ProtoFile.CollectMessages() {
+		message := m.(*fproto.MessageElement)
+		if message.IsExtend {
+			continue
+		}
+
+		err := c.generateMessage(g, message)
+		if err != nil {
+			return g.elementError(message, err)
+		}
+	}
+	return nil
+}
+
+func (c *Customizer_FieldMask) GenerateServiceCode(g *Generator) error {
+	return nil
+}
+
+func (c *Customizer_FieldMask) MessageNames(g *Generator, message *fproto.MessageElement) ([]*CustomizerName, error) {
+	msgGoName, msgProtoName := g.BuildMessageName(message)
+
+	return []*CustomizerName{
+		{Name: msgGoName + "_ValidateFieldMask", Desc: fmt.Sprintf("field mask validate function of message '%s'", msgProtoName)},
+		{StructName: msgGoName, Name: "ApplyFieldMask", Desc: fmt.Sprintf("field mask apply method of message '%s'", msgProtoName)},
+	}, nil
+}
+
+func (c *Customizer_FieldMask) generateMessage(g *Generator, message *fproto.MessageElement) error {
+	tp_msg := g.dep.DepTypeFromElement(message)
+	if tp_msg == nil {
+		return fmt.Errorf("message type not found")
+	}
+
+	msgGoName, msgProtoName := g.BuildMessageName(message)
+
+	fields, err := c.messageFields(g, tp_msg, message)
+	if err != nil {
+		return err
+	}
+
+	util_alias := g.FMain().DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util")
+	validateName := g.wrapName(g.depfile, "", msgGoName+"_ValidateFieldMask")
+	applyName := g.wrapName(g.depfile, msgGoName, "ApplyFieldMask")
+
+	//
+	// func MyMessage_ValidateFieldMask(paths []string) error
+	//
+	g.FMain().GenerateCommentLine("FIELD MASK VALIDATE: ", msgProtoName)
+
+	g.FMain().P("func ", validateName, "(paths []string) error {")
+	g.FMain().In()
+
+	g.FMain().P("fields, err := ", util_alias, ".SplitFieldMask(paths)")
+	g.FMain().P("if err != nil {")
+	g.FMain().In()
+	g.FMain().P("return err")
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().P()
+
+	g.FMain().P("for _, f := range fields {")
+	g.FMain().In()
+	g.FMain().P("switch f.Name {")
+
+	// fields without sub fields
+	var scalarNames []string
+	for _, fld := range fields {
+		if fld.tp == nil {
+			scalarNames = append(scalarNames, strconv.Quote(fld.protoName))
+		}
+	}
+	if len(scalarNames) > 0 {
+		g.FMain().P("case ", strings.Join(scalarNames, ", "), ":")
+		g.FMain().In()
+		g.FMain().P("if len(f.Paths) > 0 {")
+		g.FMain().In()
+		g.FMain().P("return f.NoSubfieldsError()")
+		g.FMain().Out()
+		g.FMain().P("}")
+		g.FMain().Out()
+	}
+
+	// wrapped message fields
+	for _, fld := range fields {
+		if fld.tp == nil {
+			continue
+		}
+
+		g.FMain().P("case ", strconv.Quote(fld.protoName), ":")
+		g.FMain().In()
+		g.FMain().P("if err := ", c.validateFuncName(g, fld.tp), "(f.Paths); err != nil {")
+		g.FMain().In()
+		g.FMain().P("return ", util_alias, ".PrefixFieldMaskError(f.Name, err)")
+		g.FMain().Out()
+		g.FMain().P("}")
+		g.FMain().Out()
+	}
+
+	g.FMain().P("default:")
+	g.FMain().In()
+	g.FMain().P("return ", util_alias, ".NewFieldMaskError(f.Name, ", strconv.Quote("unknown field of "+g.BuildMessageFullName(message)), ")")
+	g.FMain().Out()
+
+	g.FMain().P("}")
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().P("return nil")
+
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().P()
+
+	//
+	// func (m *MyMessage) ApplyFieldMask(src *MyMessage, paths []string) error
+	//
+	g.FMain().GenerateCommentLine("FIELD MASK APPLY: ", msgProtoName)
+
+	g.FMain().P("func (m *", msgGoName, ") ", applyName, "(src *", msgGoName, ", paths []string) error {")
+	g.FMain().In()
+
+	// validate first, so nothing is copied on invalid paths
+	g.FMain().P("if err := ", validateName, "(paths); err != nil {")
+	g.FMain().In()
+	g.FMain().P("return err")
+	g.FMain().Out()
+	g.FMain().P("}")
+
+	if len(fields) > 0 {
+		// a nil source clears the selected fields
+		g.FMain().P("if src == nil {")
+		g.FMain().In()
+		g.FMain().P("src = &", msgGoName, "{}")
+		g.FMain().Out()
+		g.FMain().P("}")
+		g.FMain().P()
+
+		g.FMain().P("fields, _ := ", util_alias, ".SplitFieldMask(paths)")
+		g.FMain().P("for _, f := range fields {")
+		g.FMain().In()
+		g.FMain().P("switch f.Name {")
+
+		for _, fld := range fields {
+			g.FMain().P("case ", strconv.Quote(fld.protoName), ":")
+			g.FMain().In()
+
+			switch {
+			case fld.oneofGoName != "":
+				// the oneof is set if the source has the field set, and cleared if the destination has it set
+				g.FMain().P("if _, ok := src.", fld.goName, ".(*", fld.oneofGoName, "); ok {")
+				g.FMain().In()
+				g.FMain().P("m.", fld.goName, " = src.", fld.goName)
+				g.FMain().Out()
+				g.FMain().P("} else if _, ok := m.", fld.goName, ".(*", fld.oneofGoName, "); ok {")
+				g.FMain().In()
+				g.FMain().P("m.", fld.goName, " = nil")
+				g.FMain().Out()
+				g.FMain().P("}")
+			case fld.tp != nil:
+				goTypeName, _ := g.BuildTypeName(fld.tp)
+
+				g.FMain().P("if len(f.Paths) == 0 {")
+				g.FMain().In()
+				g.FMain().P("m.", fld.goName, " = src.", fld.goName)
+				g.FMain().P("break")
+				g.FMain().Out()
+				g.FMain().P("}")
+				g.FMain().P("if m.", fld.goName, " == nil {")
+				g.FMain().In()
+				g.FMain().P("m.", fld.goName, " = &", c.typeAlias(g, fld.tp), goTypeName, "{}")
+				g.FMain().Out()
+				g.FMain().P("}")
+				g.FMain().P("if err := m.", fld.goName, ".", g.wrapName(fld.tp.DepFile, goTypeName, "ApplyFieldMask"), "(src.", fld.goName, ", f.Paths); err != nil {")
+				g.FMain().In()
+				g.FMain().P("return ", util_alias, ".PrefixFieldMaskError(f.Name, err)")
+				g.FMain().Out()
+				g.FMain().P("}")
+			default:
+				g.FMain().P("m.", fld.goName, " = src.", fld.goName)
+			}
+
+			g.FMain().Out()
+		}
+
+		g.FMain().P("}")
+		g.FMain().Out()
+		g.FMain().P("}")
+	}
+	g.FMain().P("return nil")
+
+	g.FMain().Out()
+	g.FMain().P("}")
+	g.FMain().P()
+
+	return nil
+}
+
+// Returns the fields of the message that can be selected by a field mask, in declaration order
+func (c *Customizer_FieldMask) messageFields(g *Generator, tp_msg *fdep.DepType, message *fproto.MessageElement) ([]*fieldMaskField, error) {
+	var ret []*fieldMaskField
+	for _, fld := range message.Fields {
+		fldGoName, fldProtoName := g.BuildFieldName(fld)
+
+		switch xfld := fld.(type) {
+		case *fproto.FieldElement:
+			f := &fieldMaskField{
+				protoName: fldProtoName,
+				goName:    fldGoName,
+			}
+			if !xfld.Repeated {
+				tp, err := tp_msg.GetType(xfld.Type)
+				if err != nil {
+					return nil, err
+				}
+				wrapped, err := c.isWrappedMessage(g, tp)
+				if err != nil {
+					return nil, err
+				}
+				if wrapped {
+					f.tp = tp
+				}
+			}
+			ret = append(ret, f)
+		case *fproto.MapFieldElement:
+			ret = append(ret, &fieldMaskField{
+				protoName: fldProtoName,
+				goName:    fldGoName,
+			})
+		case *fproto.OneOfFieldElement:
+			// the oneof members are selected by their own names
+			for _, oofld := range xfld.Fields {
+				_, oofldProtoName := g.BuildFieldName(oofld)
+				oneofFieldGoName, _ := g.BuildOneOfFieldName(oofld)
+				ret = append(ret, &fieldMaskField{
+					protoName:   oofldProtoName,
+					goName:      fldGoName,
+					oneofGoName: oneofFieldGoName,
+				})
+			}
+		}
+	}
+	return ret, nil
+}
+
+// Returns whether the type is a message converted to its wrapped struct, which has the field mask helpers
+func (c *Customizer_FieldMask) isWrappedMessage(g *Generator, tp *fdep.DepType) (bool, error) {
+	if tp.IsScalar() {
+		return false, nil
+	}
+	if _, ismsg := tp.Item.(*fproto.MessageElement); !ismsg {
+		return false, nil
+	}
+	tc, err := g.GetTypeConverter(tp)
+	if err != nil {
+		return false, err
+	}
+	return tc.TCID() == TCID_DEFAULT && g.IsFileWrap(tp.DepFile), nil
+}
+
+// Returns the package prefix of a wrapped type, blank if on the same package
+func (c *Customizer_FieldMask) typeAlias(g *Generator, tp *fdep.DepType) string {
+	if tp.DepFile.IsSamePackage(g.depfile) {
+		return ""
+	}
+	return g.FMain().DeclFileDep(tp.DepFile, tp.Alias, true) + "."
+}
+
+// Returns the validate function of a wrapped message type
+func (c *Customizer_FieldMask) validateFuncName(g *Generator, tp *fdep.DepType) string {
+	goTypeName, _ := g.BuildTypeName(tp)
+	return c.typeAlias(g, tp) + g.wrapName(tp.DepFile, "", goTypeName+"_ValidateFieldMask")
+}
@@ -0,0 +1,50 @@
+package fproto_gowrap
+
+import (
+	"testing"
+)
+
+func TestTypeConverterFieldMask(t *testing.T) {
+	tests := []struct {
+		format   FieldMaskFormat
+		typeName string
+		suffix   string
+	}{
+		{FIELDMASKFORMAT_SLICE, "[]string", "FieldMask"},
+		{FIELDMASKFORMAT_PATHS, "fproto_gowrap_util.FieldPaths", "FieldPaths"},
+	}
+
+	for _, test := range tests {
+		plugin := NewTypeConverterPlugin_FieldMask()
+		plugin.Format = test.format
+
+		gf, tc := testTypeConverter(t, plugin, testWellKnownType(FIELDMASK_FILEPATH, "FieldMask"))
+		if tc.TCID() != TCID_FIELDMASK {
+			t.Fatalf("%s: expected the field mask converter, got %s", test.suffix, tc.TCID())
+		}
+		if name := tc.TypeName(gf, TNT_TYPENAME, 0); name != test.typeName {
+			t.Errorf("%s: unexpected type name %s", test.suffix, name)
+		}
+		if empty := tc.TypeName(gf, TNT_EMPTYORNILVALUE, 0); empty != "nil" {
+			t.Errorf("%s: unexpected nil value %s", test.suffix, empty)
+		}
+
+		code := testConverterCode(t, gf, false, func() (bool, error) {
+			return tc.GenerateImport(gf, "s.Mask", "ret.Mask", "err")
+		})
+		if expected := "ret.Mask = fproto_gowrap_util.Import" + test.suffix + "(s.Mask)\n"; code != expected {
+			t.Errorf("%s: unexpected import code:\n%s\nexpected:\n%s", test.suffix, code, expected)
+		}
+
+		code = testConverterCode(t, gf, false, func() (bool, error) {
+			return tc.GenerateExport(gf, "m.Mask", "ret.Mask", "err")
+		})
+		if expected := "ret.Mask = fproto_gowrap_util.Export" + test.suffix + "(m.Mask)\n"; code != expected {
+			t.Errorf("%s: unexpected export code:\n%s\nexpected:\n%s", test.suffix, code, expected)
+		}
+	}
+
+	if _, err := ParseFieldMaskFormat("set"); err == nil {
+		t.Error("expected an invalid format error")
+	}
+}
@@ -0,0 +1,108 @@
+package fproto_gowrap
+
+import (
+	"github.com/RangelReale/fdep"
+)
+
+// Proto file of the Struct, Value and ListValue types
+const STRUCT_FILEPATH = "google/protobuf/struct.proto"
+
+// Go type and conversion helpers of the Struct types
+type structType struct {
+	goType string
+	// Conversion helpers on the gowrap util package
+	importFunc string
+	exportFunc string
+}
+
+var structTypes = map[string]*structType{
+	"Struct":    {goType: "map[string]interface{}", importFunc: "ImportStruct", exportFunc: "ExportStruct"},
+	"Value":     {goType: "interface{}", importFunc: "ImportValue", exportFunc: "ExportValue"},
+	"ListValue": {goType: "[]interface{}", importFunc: "ImportListValue", exportFunc: "ExportListValue"},
+}
+
+//
+// TypeConverterPlugin: Struct
+//
+
+// Type converter plugin for google.protobuf.Struct, Value and ListValue, converted to map[string]interface{},
+// interface{} and []interface{}, using the conversion functions of the gowrap util package.
+type TypeConverterPlugin_Struct struct {
+}
+
+func NewTypeConverterPlugin_Struct() *TypeConverterPlugin_Struct {
+	return &TypeConverterPlugin_Struct{}
+}
+
+func init() {
+	RegisterTypeConverterPlugin("struct", func(options map[string]string) (TypeConverterPlugin, error) {
+		return NewTypeConverterPlugin_Struct(), nil
+	})
+}
+
+func (t *TypeConverterPlugin_Struct) GetTypeConverter(tp *fdep.DepType) TypeConverter {
+	if tp.DepFile == nil || tp.DepFile.FilePath != STRUCT_FILEPATH {
+		return nil
+	}
+
+	st, ok := structTypes[tp.Name]
+	if !ok {
+		return nil
+	}
+
+	return &TypeConverter_Struct{
+		tp: tp,
+		st: st,
+	}
+}
+
+//
+// TypeConverter: Struct
+//
+
+const (
+	TCID_STRUCT TCID = "d75900eb-86cb-49e8-9f40-53d9caeff9c2"
+)
+
+// Type converter for google.protobuf.Struct, Value or ListValue
+type TypeConverter_Struct struct {
+	tp *fdep.DepType
+	st *structType
+}
+
+func (t *TypeConverter_Struct) TCID() TCID {
+	return TCID_STRUCT
+}
+
+func (t *TypeConverter_Struct) TypeName(g *GeneratorFile, tntype TypeNameType, options uint32) string {
+	switch tntype {
+	case TNT_EMPTYVALUE:
+		if t.st.goType == "interface{}" {
+			return "nil"
+		}
+		return t.st.goType + "{}"
+	case TNT_EMPTYORNILVALUE:
+		return "nil"
+	}
+	return t.st.goType
+}
+
+func (t *TypeConverter_Struct) IsPointer() bool {
+	return false
+}
+
+func (t *TypeConverter_Struct) GenerateImport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	util_alias := g.DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util")
+
+	// varDest = fproto_gowrap_util.ImportStruct(varSrc)
+	g.P(varDest, " = ", util_alias, ".", t.st.importFunc, "(", varSrc, ")")
+	return false, nil
+}
+
+func (t *TypeConverter_Struct) GenerateExport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	util_alias := g.DeclDep("github.com/RangelReale/fproto-wrap/gowrap/util", "fproto_gowrap_util")
+
+	// varDest, err = fproto_gowrap_util.ExportStruct(varSrc)
+	g.P(varDest, ", ", varError, " = ", util_alias, ".", t.st.exportFunc, "(", varSrc, ")")
+	return true, nil
+}
@@ -0,0 +1,46 @@
+package fproto_gowrap
+
+import (
+	"testing"
+)
+
+func TestTypeConverterStruct(t *testing.T) {
+	tests := []struct {
+		name       string
+		typeName   string
+		importFunc string
+		exportFunc string
+		emptyValue string
+	}{
+		{"Struct", "map[string]interface{}", "ImportStruct", "ExportStruct", "map[string]interface{}{}"},
+		{"Value", "interface{}", "ImportValue", "ExportValue", "nil"},
+		{"ListValue", "[]interface{}", "ImportListValue", "ExportListValue", "[]interface{}{}"},
+	}
+
+	for _, test := range tests {
+		gf, tc := testTypeConverter(t, NewTypeConverterPlugin_Struct(), testWellKnownType(STRUCT_FILEPATH, test.name))
+		if tc.TCID() != TCID_STRUCT {
+			t.Fatalf("%s: expected the struct converter, got %s", test.name, tc.TCID())
+		}
+		if name := tc.TypeName(gf, TNT_TYPENAME, 0); name != test.typeName {
+			t.Errorf("%s: unexpected type name %s", test.name, name)
+		}
+		if empty := tc.TypeName(gf, TNT_EMPTYVALUE, 0); empty != test.emptyValue {
+			t.Errorf("%s: unexpected empty value %s", test.name, empty)
+		}
+
+		code := testConverterCode(t, gf, false, func() (bool, error) {
+			return tc.GenerateImport(gf, "s.Data", "ret.Data", "err")
+		})
+		if expected := "ret.Data = fproto_gowrap_util." + test.importFunc + "(s.Data)\n"; code != expected {
+			t.Errorf("%s: unexpected import code:\n%s\nexpected:\n%s", test.name, code, expected)
+		}
+
+		code = testConverterCode(t, gf, true, func() (bool, error) {
+			return tc.GenerateExport(gf, "m.Data", "ret.Data", "err")
+		})
+		if expected := "ret.Data, err = fproto_gowrap_util." + test.exportFunc + "(m.Data)\n"; code != expected {
+			t.Errorf("%s: unexpected export code:\n%s\nexpected:\n%s", test.name, code, expected)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package fproto_gowrap
+
+import (
+	"fmt"
+
+	"github.com/RangelReale/fdep"
+)
+
+// Proto file of the wrapper types
+const WRAPPERS_FILEPATH = "google/protobuf/wrappers.proto"
+
+// Go type of the wrapper types
+type WrappersFormat int
+
+const (
+	// Pointer to the value type (*string, *int64, ...). Unset values are nil.
+	WRAPPERSFORMAT_POINTER WrappersFormat = iota
+	// database/sql null types (sql.NullString, sql.NullInt64, ...). Unset values are not Valid.
+	// UInt64Value has no sql type, and is always converted to *uint64.
+	WRAPPERSFORMAT_SQL
+)
+
+// Parses a wrappers format name: "pointer" (or blank) or "sql"
+func ParseWrappersFormat(name string) (WrappersFormat, error) {
+	switch name {
+	case "", "pointer":
+		return WRAPPERSFORMAT_POINTER, nil
+	case "sql":
+		return WRAPPERSFORMAT_SQL, nil
+	}
+	return WRAPPERSFORMAT_POINTER, fmt.Errorf("Invalid wrappers format: %s", name)
+}
+
+// Go types of a wrapper type
+type wrappersType struct {
+	// Go type of the Value field
+	goType string
+	// database/sql type and its value field, blank if none
+	sqlType  string
+	sqlField string
+	// Go type of the sql value field
+	sqlGoType string
+	// Range of the proto value type, checked on export from a wider sql type. Blank if not needed.
+	sqlMin string
+	sqlMax string
+}
+
+var wrappersTypes = map[string]*wrappersType{
+	"DoubleValue": {goType: "float64", sqlType: "NullFloat64", sqlField: "Float64", sqlGoType: "float64"},
+	"FloatValue":  {goType: "float32", sqlType: "NullFloat64", sqlField: "Float64", sqlGoType: "float64"},
+	"Int64Value":  {goType: "int64", sqlType: "NullInt64", sqlField: "Int64", sqlGoType: "int64"},
+	"UInt64Value": {goType: "uint64"},
+	"Int32Value":  {goType: "int32", sqlType: "NullInt64", sqlField: "Int64", sqlGoType: "int64", sqlMin: "MinInt32", sqlMax: "MaxInt32"},
+	"UInt32Value": {goType: "uint32", sqlType: "NullInt64", sqlField: "Int64", sqlGoType: "int64", sqlMin: "0", sqlMax: "MaxUint32"},
+	"BoolValue":   {goType: "bool", sqlType: "NullBool", sqlField: "Bool", sqlGoType: "bool"},
+	"StringValue": {goType: "string", sqlType: "NullString", sqlField: "String", sqlGoType: "string"},
+	"BytesValue":  {goType: "[]byte"},
+}
+
+//
+// TypeConverterPlugin: Wrappers
+//
+
+// Type converter plugin for the google.protobuf wrapper types (StringValue, Int64Value, ...).
+// Unset (nil) values are kept unset on import and export.
+type TypeConverterPlugin_Wrappers struct {
+	Format WrappersFormat
+}
+
+func NewTypeConverterPlugin_Wrappers() *TypeConverterPlugin_Wrappers {
+	return &TypeConverterPlugin_Wrappers{
+		Format: WRAPPERSFORMAT_POINTER,
+	}
+}
+
+func init() {
+	// options: format=pointer|sql
+	RegisterTypeConverterPlugin("wrappers", func(options map[string]string) (TypeConverterPlugin, error) {
+		ret := NewTypeConverterPlugin_Wrappers()
+		format, err := ParseWrappersFormat(options["format"])
+		if err != nil {
+			return nil, err
+		}
+		ret.Format = format
+		return ret, nil
+	})
+}
+
+func (t *TypeConverterPlugin_Wrappers) GetTypeConverter(tp *fdep.DepType) TypeConverter {
+	if tp.DepFile == nil || tp.DepFile.FilePath != WRAPPERS_FILEPATH {
+		return nil
+	}
+
+	wt, ok := wrappersTypes[tp.Name]
+	if !ok {
+		return nil
+	}
+
+	return &TypeConverter_Wrappers{
+		tp:     tp,
+		wt:     wt,
+		useSql: t.Format == WRAPPERSFORMAT_SQL && wt.sqlType != "",
+	}
+}
+
+//
+// TypeConverter: Wrappers
+//
+
+const (
+	TCID_WRAPPERS TCID = "8793af7f-6586-4174-9ea0-11c364c23606"
+)
+
+// Type converter for a google.protobuf wrapper type
+type TypeConverter_Wrappers struct {
+	tp     *fdep.DepType
+	wt     *wrappersType
+	useSql bool
+}
+
+func (t *TypeConverter_Wrappers) TCID() TCID {
+	return TCID_WRAPPERS
+}
+
+func (t *TypeConverter_Wrappers) TypeName(g *GeneratorFile, tntype TypeNameType, options uint32) string {
+	if t.useSql {
+		sql_alias := g.DeclDep("database/sql", "sql")
+		switch tntype {
+		case TNT_EMPTYVALUE, TNT_EMPTYORNILVALUE:
+			return sql_alias + "." + t.wt.sqlType + "{}"
+		}
+		return sql_alias + "." + t.wt.sqlType
+	}
+
+	if t.isBytes() {
+		switch tntype {
+		case TNT_EMPTYVALUE:
+			return "[]byte{}"
+		case TNT_EMPTYORNILVALUE:
+			return "nil"
+		}
+		return t.wt.goType
+	}
+
+	switch tntype {
+	case TNT_EMPTYVALUE:
+		return "new(" + t.wt.goType + ")"
+	case TNT_EMPTYORNILVALUE:
+		return "nil"
+	}
+	return "*" + t.wt.goType
+}
+
+func (t *TypeConverter_Wrappers) IsPointer() bool {
+	return !t.useSql && !t.isBytes()
+}
+
+func (t *TypeConverter_Wrappers) GeneratePresence(g *GeneratorFile, varSrc string) (string, error) {
+	if t.useSql {
+		return varSrc + ".Valid", nil
+	}
+	return varSrc + " != nil", nil
+}
+
+func (t *TypeConverter_Wrappers) GenerateClear(g *GeneratorFile, varDest string) error {
+	g.P(varDest, " = ", t.TypeName(g, TNT_EMPTYORNILVALUE, 0))
+	return nil
+}
+
+func (t *TypeConverter_Wrappers) GenerateImport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	g.P("if ", varSrc, " != nil {")
+	g.In()
+	switch {
+	case t.useSql:
+		// varDest = sql.NullInt64{Int64: int64(varSrc.Value), Valid: true}
+		value := varSrc + ".Value"
+		if t.wt.sqlGoType != t.wt.goType {
+			value = t.wt.sqlGoType + "(" + value + ")"
+		}
+		g.P(varDest, " = ", t.TypeName(g, TNT_TYPENAME, 0), "{", t.wt.sqlField, ": ", value, ", Valid: true}")
+	case t.isBytes():
+		// an empty value is still set
+		g.P(varDest, " = append([]byte{}, ", varSrc, ".Value...)")
+	default:
+		g.P("wrapValue := ", varSrc, ".Value")
+		g.P(varDest, " = &wrapValue")
+	}
+	g.Out()
+	g.P("} else {")
+	g.In()
+	g.P(varDest, " = ", t.TypeName(g, TNT_EMPTYORNILVALUE, 0))
+	g.Out()
+	g.P("}")
+
+	return false, nil
+}
+
+func (t *TypeConverter_Wrappers) GenerateExport(g *GeneratorFile, varSrc string, varDest string, varError string) (checkError bool, err error) {
+	src_alias := g.DeclFileDep(t.tp.DepFile, t.tp.Alias, false)
+	// the wrapper types are top level messages, with the same Go name
+	goTypeName := t.tp.Name
+
+	has, _ := t.GeneratePresence(g, varSrc)
+	g.P("if ", has, " {")
+	g.In()
+	switch {
+	case t.useSql:
+		value := varSrc + "." + t.wt.sqlField
+		if t.wt.sqlMin != "" {
+			// narrowing conversion
+			checkError = true
+
+			math_alias := g.DeclDep("math", "math")
+			fmt_alias := g.DeclDep("fmt", "fmt")
+			min := t.wt.sqlMin
+			if min != "0" {
+				min = math_alias + "." + min
+			}
+
+			g.P("if ", value, " < ", min, " || ", value, " > ", math_alias, ".", t.wt.sqlMax, " {")
+			g.In()
+			g.P(varError, " = ", fmt_alias, ".Errorf(\"Value %d is out of range for google.protobuf.", t.tp.Name, "\", ", value, ")")
+			g.Out()
+			g.P("}")
+		}
+		if t.wt.sqlGoType != t.wt.goType {
+			value = t.wt.goType + "(" + value + ")"
+		}
+		g.P(varDest, " = &", src_alias, ".", goTypeName, "{Value: ", value, "}")
+	case t.isBytes():
+		g.P(varDest, " = &", src_alias, ".", goTypeName, "{Value: ", varSrc, "}")
+	default:
+		g.P(varDest, " = &", src_alias, ".", goTypeName, "{Value: *", varSrc, "}")
+	}
+	g.Out()
+	g.P("} else {")
+	g.In()
+	g.P(varDest, " = nil")
+	g.Out()
+	g.P("}")
+
+	return checkError, nil
+}
+
+func (t *TypeConverter_Wrappers) isBytes() bool {
+	return t.wt.goType == "[]byte"
+}
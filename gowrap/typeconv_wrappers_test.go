@@ -0,0 +1,164 @@
+package fproto_gowrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/RangelReale/fdep"
+	"github.com/RangelReale/fproto"
+)
+
+// Returns a type of an imported well-known proto file
+func testWellKnownType(filepath string, name string) *fdep.DepType {
+	return &fdep.DepType{
+		DepFile: &fdep.DepFile{
+			FilePath:  filepath,
+			DepType:   fdep.DepType_Imported,
+			ProtoFile: &fproto.ProtoFile{},
+		},
+		Alias:         "google_protobuf",
+		OriginalAlias: "google.protobuf",
+		Name:          name,
+	}
+}
+
+// Returns the converter of the type from the plugin
+func testTypeConverter(t *testing.T, plugin TypeConverterPlugin, tp *fdep.DepType) (*GeneratorFile, TypeConverter) {
+	g, err := NewGenerator(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.TypeConverters = []TypeConverterPlugin{plugin}
+
+	tc, err := g.GetTypeConverter(tp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewGeneratorFileFixed(g, "test", "test.go"), tc
+}
+
+// Returns the code generated by the function, checking the returned checkError
+func testConverterCode(t *testing.T, gf *GeneratorFile, expectedCheckError bool, generate func() (bool, error)) string {
+	gf.Reset()
+	checkError, err := generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkError != expectedCheckError {
+		t.Errorf("expected checkError %v, got %v", expectedCheckError, checkError)
+	}
+	return gf.String()
+}
+
+func TestTypeConverterWrappersNil(t *testing.T) {
+	tp := testWellKnownType(WRAPPERS_FILEPATH, "StringValue")
+	gf, tc := testTypeConverter(t, NewTypeConverterPlugin_Wrappers(), tp)
+	if tc.TCID() != TCID_WRAPPERS {
+		t.Fatalf("expected the wrappers converter, got %s", tc.TCID())
+	}
+	if name := tc.TypeName(gf, TNT_TYPENAME, 0); name != "*string" {
+		t.Errorf("unexpected type name %s", name)
+	}
+	src_alias := gf.DeclFileDep(tp.DepFile, tp.Alias, false)
+
+	// the variables of repeated and map elements, and of oneof fields
+	contexts := []struct {
+		name string
+		src  string
+		dest string
+	}{
+		{"repeated", "ms", "msi"},
+		{"map", "ms", "msi"},
+		{"oneof", "s.Name", "ret.Name"},
+	}
+
+	for _, c := range contexts {
+		code := testConverterCode(t, gf, false, func() (bool, error) {
+			return tc.GenerateImport(gf, c.src, c.dest, "err")
+		})
+		expected := `if ` + c.src + ` != nil {
+	wrapValue := ` + c.src + `.Value
+	` + c.dest + ` = &wrapValue
+} else {
+	` + c.dest + ` = nil
+}
+`
+		if code != expected {
+			t.Errorf("%s: unexpected import code:\n%s\nexpected:\n%s", c.name, code, expected)
+		}
+
+		code = testConverterCode(t, gf, false, func() (bool, error) {
+			return tc.GenerateExport(gf, c.dest, c.src, "err")
+		})
+		expected = `if ` + c.dest + ` != nil {
+	` + c.src + ` = &` + src_alias + `.StringValue{Value: *` + c.dest + `}
+} else {
+	` + c.src + ` = nil
+}
+`
+		if code != expected {
+			t.Errorf("%s: unexpected export code:\n%s\nexpected:\n%s", c.name, code, expected)
+		}
+	}
+}
+
+func TestTypeConverterWrappersBytes(t *testing.T) {
+	tp := testWellKnownType(WRAPPERS_FILEPATH, "BytesValue")
+	gf, tc := testTypeConverter(t, NewTypeConverterPlugin_Wrappers(), tp)
+	if name := tc.TypeName(gf, TNT_TYPENAME, 0); name != "[]byte" {
+		t.Errorf("unexpected type name %s", name)
+	}
+
+	// an empty value is set, and only nil is unset
+	code := testConverterCode(t, gf, false, func() (bool, error) {
+		return tc.GenerateImport(gf, "ms", "msi", "err")
+	})
+	expected := `if ms != nil {
+	msi = append([]byte{}, ms.Value...)
+} else {
+	msi = nil
+}
+`
+	if code != expected {
+		t.Errorf("unexpected import code:\n%s\nexpected:\n%s", code, expected)
+	}
+}
+
+func TestTypeConverterWrappersSql(t *testing.T) {
+	plugin := NewTypeConverterPlugin_Wrappers()
+	plugin.Format = WRAPPERSFORMAT_SQL
+
+	tp := testWellKnownType(WRAPPERS_FILEPATH, "Int32Value")
+	gf, tc := testTypeConverter(t, plugin, tp)
+	if name := tc.TypeName(gf, TNT_TYPENAME, 0); name != "sql.NullInt64" {
+		t.Errorf("unexpected type name %s", name)
+	}
+
+	code := testConverterCode(t, gf, false, func() (bool, error) {
+		return tc.GenerateImport(gf, "ms", "msi", "err")
+	})
+	expected := `if ms != nil {
+	msi = sql.NullInt64{Int64: int64(ms.Value), Valid: true}
+} else {
+	msi = sql.NullInt64{}
+}
+`
+	if code != expected {
+		t.Errorf("unexpected import code:\n%s\nexpected:\n%s", code, expected)
+	}
+
+	// the value is range checked
+	code = testConverterCode(t, gf, true, func() (bool, error) {
+		return tc.GenerateExport(gf, "msi", "ms", "err")
+	})
+	if !strings.HasPrefix(code, "if msi.Valid {\n\tif msi.Int64 < math.MinInt32 || msi.Int64 > math.MaxInt32 {\n") ||
+		!strings.HasSuffix(code, "} else {\n\tms = nil\n}\n") {
+		t.Errorf("unexpected export code:\n%s", code)
+	}
+
+	// UInt64Value has no sql type
+	_, tc = testTypeConverter(t, plugin, testWellKnownType(WRAPPERS_FILEPATH, "UInt64Value"))
+	if name := tc.TypeName(gf, TNT_TYPENAME, 0); name != "*uint64" {
+		t.Errorf("unexpected type name %s", name)
+	}
+}
@@ -0,0 +1,141 @@
+package fproto_gowrap_util
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	anypb "github.com/golang/protobuf/ptypes/any"
+)
+
+// A wrapped message type that can be converted from and to google.protobuf.Any.
+// The wrapped packages register their types on their init function, when generated with the any_registry customizer.
+type AnyType struct {
+	// Full proto name of the message, like "mypackage.MyMessage"
+	Name string
+
+	// Nil pointer to the wrapped struct, like (*MyMessage)(nil)
+	Value interface{}
+
+	// Imports the protoc-gen-go generated message to the wrapped struct
+	Import func(s proto.Message) (interface{}, error)
+
+	// Exports the wrapped struct to the protoc-gen-go generated message
+	Export func(v interface{}) (proto.Message, error)
+}
+
+var (
+	anyTypesLock      sync.RWMutex
+	anyTypesByName    = make(map[string]*AnyType)
+	anyTypesByType    = make(map[reflect.Type]*AnyType)
+	anyTypesConflicts = make(map[string][]reflect.Type)
+)
+
+// Registers a wrapped message type for google.protobuf.Any conversion.
+// If another wrapped type was registered with the same name, like when two wrap packages of the same proto file are
+// linked in, the first one is kept for the name, and importing an Any of that name returns an error listing both.
+// Each wrapped type can still be exported.
+func RegisterAnyType(t *AnyType) {
+	anyTypesLock.Lock()
+	defer anyTypesLock.Unlock()
+
+	tp := reflect.TypeOf(t.Value)
+	if _, ok := anyTypesByType[tp]; ok {
+		return
+	}
+	anyTypesByType[tp] = t
+
+	if first, ok := anyTypesByName[t.Name]; ok {
+		if len(anyTypesConflicts[t.Name]) == 0 {
+			anyTypesConflicts[t.Name] = []reflect.Type{reflect.TypeOf(first.Value)}
+		}
+		anyTypesConflicts[t.Name] = append(anyTypesConflicts[t.Name], tp)
+		return
+	}
+	anyTypesByName[t.Name] = t
+}
+
+// Returns the wrapped message type registered with the full proto name. If more than one was registered, returns
+// the first one, see AnyTypeConflicts.
+func LookupAnyType(name string) (*AnyType, bool) {
+	anyTypesLock.RLock()
+	defer anyTypesLock.RUnlock()
+
+	t, ok := anyTypesByName[name]
+	return t, ok
+}
+
+// Returns the wrapped types registered with the full proto name, in registration order, if there is more than one
+func AnyTypeConflicts(name string) []reflect.Type {
+	anyTypesLock.RLock()
+	defer anyTypesLock.RUnlock()
+
+	return append([]reflect.Type(nil), anyTypesConflicts[name]...)
+}
+
+// Returns the wrapped message type of a wrapped value
+func LookupAnyTypeOf(v interface{}) (*AnyType, bool) {
+	anyTypesLock.RLock()
+	defer anyTypesLock.RUnlock()
+
+	t, ok := anyTypesByType[reflect.TypeOf(v)]
+	return t, ok
+}
+
+// Imports a google.protobuf.Any as the wrapped struct of its message, which must be registered with RegisterAnyType.
+// A nil Any is imported as nil.
+func ImportAny(s *anypb.Any) (interface{}, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	name, err := ptypes.AnyMessageName(s)
+	if err != nil {
+		return nil, err
+	}
+
+	t, ok := LookupAnyType(name)
+	if !ok {
+		return nil, fmt.Errorf("Message type %s of google.protobuf.Any is not a registered wrapped type", name)
+	}
+	if conflicts := AnyTypeConflicts(name); len(conflicts) > 0 {
+		return nil, fmt.Errorf("Message type %s of google.protobuf.Any is registered by more than one wrapped type: %v", name, conflicts)
+	}
+
+	msg, err := ptypes.Empty(s)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ptypes.UnmarshalAny(s, msg)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshaling google.protobuf.Any of type %s: %v", name, err)
+	}
+
+	return t.Import(msg)
+}
+
+// Exports a wrapped struct, whose type must be registered with RegisterAnyType, as a google.protobuf.Any.
+// A nil value is exported as a nil Any.
+func ExportAny(v interface{}) (*anypb.Any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil, nil
+	}
+
+	t, ok := LookupAnyTypeOf(v)
+	if !ok {
+		return nil, fmt.Errorf("Type %T is not a registered wrapped type for google.protobuf.Any", v)
+	}
+
+	msg, err := t.Export(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return ptypes.MarshalAny(msg)
+}
@@ -0,0 +1,136 @@
+package fproto_gowrap_util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+)
+
+// Wrapped structs of the google.protobuf wrapper messages
+type testAnyString struct {
+	Value string
+}
+
+type testAnyInt struct {
+	Value int64
+}
+
+type testAnyIntOther struct {
+	Value int64
+}
+
+func testAnyStringType() *AnyType {
+	return &AnyType{
+		Name:  "google.protobuf.StringValue",
+		Value: (*testAnyString)(nil),
+		Import: func(s proto.Message) (interface{}, error) {
+			return &testAnyString{Value: s.(*wrappers.StringValue).Value}, nil
+		},
+		Export: func(v interface{}) (proto.Message, error) {
+			return &wrappers.StringValue{Value: v.(*testAnyString).Value}, nil
+		},
+	}
+}
+
+func TestAnyRoundTrip(t *testing.T) {
+	RegisterAnyType(testAnyStringType())
+
+	a, err := ExportAny(&testAnyString{Value: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.TypeUrl != "type.googleapis.com/google.protobuf.StringValue" {
+		t.Errorf("unexpected type url %s", a.TypeUrl)
+	}
+
+	v, err := ImportAny(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, &testAnyString{Value: "x"}) {
+		t.Errorf("unexpected imported value %v", v)
+	}
+
+	// nil is kept
+	if v, err := ImportAny(nil); v != nil || err != nil {
+		t.Errorf("expected nil, got %v, %v", v, err)
+	}
+	if a, err := ExportAny(nil); a != nil || err != nil {
+		t.Errorf("expected a nil Any, got %v, %v", a, err)
+	}
+	if a, err := ExportAny((*testAnyString)(nil)); a != nil || err != nil {
+		t.Errorf("expected a nil Any, got %v, %v", a, err)
+	}
+}
+
+func TestAnyUnregistered(t *testing.T) {
+	a, err := ptypes.MarshalAny(&wrappers.BoolValue{Value: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ImportAny(a)
+	if err == nil || !strings.Contains(err.Error(), "google.protobuf.BoolValue of google.protobuf.Any is not a registered wrapped type") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = ExportAny(&struct{ Value bool }{true})
+	if err == nil || !strings.Contains(err.Error(), "is not a registered wrapped type for google.protobuf.Any") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestAnyDuplicateRegistration(t *testing.T) {
+	register := func(value interface{}, wrap func(int64) interface{}, unwrap func(interface{}) int64) {
+		RegisterAnyType(&AnyType{
+			Name:  "google.protobuf.Int64Value",
+			Value: value,
+			Import: func(s proto.Message) (interface{}, error) {
+				return wrap(s.(*wrappers.Int64Value).Value), nil
+			},
+			Export: func(v interface{}) (proto.Message, error) {
+				return &wrappers.Int64Value{Value: unwrap(v)}, nil
+			},
+		})
+	}
+	register((*testAnyInt)(nil),
+		func(v int64) interface{} { return &testAnyInt{Value: v} },
+		func(v interface{}) int64 { return v.(*testAnyInt).Value })
+	// registering the same Go type again is ignored
+	register((*testAnyInt)(nil),
+		func(v int64) interface{} { return &testAnyInt{Value: v} },
+		func(v interface{}) int64 { return v.(*testAnyInt).Value })
+
+	if c := AnyTypeConflicts("google.protobuf.Int64Value"); len(c) != 0 {
+		t.Fatalf("unexpected conflicts %v", c)
+	}
+
+	register((*testAnyIntOther)(nil),
+		func(v int64) interface{} { return &testAnyIntOther{Value: v} },
+		func(v interface{}) int64 { return v.(*testAnyIntOther).Value })
+
+	// the first one is kept for the name
+	if tp, ok := LookupAnyType("google.protobuf.Int64Value"); !ok || tp.Value != (*testAnyInt)(nil) {
+		t.Errorf("expected the first registered type, got %v", tp)
+	}
+	expected := []reflect.Type{reflect.TypeOf((*testAnyInt)(nil)), reflect.TypeOf((*testAnyIntOther)(nil))}
+	if c := AnyTypeConflicts("google.protobuf.Int64Value"); !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected conflicts %v, got %v", expected, c)
+	}
+
+	// both can be exported, but not imported
+	for _, v := range []interface{}{&testAnyInt{Value: 1}, &testAnyIntOther{Value: 2}} {
+		a, err := ExportAny(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ImportAny(a)
+		if err == nil || !strings.Contains(err.Error(), "is registered by more than one wrapped type") ||
+			!strings.Contains(err.Error(), "testAnyIntOther") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
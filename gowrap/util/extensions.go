@@ -0,0 +1,54 @@
+package fproto_gowrap_util
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Extension values of a wrapped extendable message, keyed by field number.
+// The values are the types returned by proto.GetExtension for the protoc-gen-go generated extension.
+// Only extensions registered by a linked-in protoc-gen-go package are imported.
+type Extensions map[int32]interface{}
+
+// Imports the registered extensions set on a protoc-gen-go generated message
+func ImportExtensions(s proto.Message) (Extensions, error) {
+	var ret Extensions
+	for field, desc := range proto.RegisteredExtensions(s) {
+		if !proto.HasExtension(s, desc) {
+			continue
+		}
+
+		v, err := proto.GetExtension(s, desc)
+		if err != nil {
+			return nil, fmt.Errorf("Error importing extension %s: %v", desc.Name, err)
+		}
+
+		if ret == nil {
+			ret = make(Extensions)
+		}
+		ret[field] = v
+	}
+	return ret, nil
+}
+
+// Exports the extensions to a protoc-gen-go generated message
+func (e Extensions) Export(d proto.Message) error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	descs := proto.RegisteredExtensions(d)
+	for field, v := range e {
+		desc, ok := descs[field]
+		if !ok {
+			return fmt.Errorf("Extension %d is not registered for message %s", field, proto.MessageName(d))
+		}
+
+		err := proto.SetExtension(d, desc, v)
+		if err != nil {
+			return fmt.Errorf("Error exporting extension %s: %v", desc.Name, err)
+		}
+	}
+	return nil
+}
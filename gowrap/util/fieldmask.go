@@ -0,0 +1,172 @@
+package fproto_gowrap_util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	field_mask "google.golang.org/genproto/protobuf/field_mask"
+)
+
+// Imports a google.protobuf.FieldMask as its paths. A nil FieldMask is imported as a nil slice.
+func ImportFieldMask(s *field_mask.FieldMask) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s.Paths...)
+}
+
+// Exports paths as a google.protobuf.FieldMask. A nil slice is exported as a nil FieldMask.
+func ExportFieldMask(v []string) *field_mask.FieldMask {
+	if v == nil {
+		return nil
+	}
+	return &field_mask.FieldMask{Paths: append([]string{}, v...)}
+}
+
+// Imports a google.protobuf.FieldMask as a path set. A nil FieldMask is imported as a nil set.
+func ImportFieldPaths(s *field_mask.FieldMask) FieldPaths {
+	if s == nil {
+		return nil
+	}
+	return NewFieldPaths(s.Paths...)
+}
+
+// Exports a path set as a google.protobuf.FieldMask, with the paths sorted. A nil set is exported as a nil FieldMask.
+func ExportFieldPaths(v FieldPaths) *field_mask.FieldMask {
+	if v == nil {
+		return nil
+	}
+	return &field_mask.FieldMask{Paths: v.Paths()}
+}
+
+//
+// FieldPaths
+//
+
+// A set of field mask paths, like "name" or "address.city"
+type FieldPaths map[string]struct{}
+
+func NewFieldPaths(paths ...string) FieldPaths {
+	ret := make(FieldPaths, len(paths))
+	ret.Add(paths...)
+	return ret
+}
+
+// Adds paths to the set
+func (p FieldPaths) Add(paths ...string) {
+	for _, path := range paths {
+		p[path] = struct{}{}
+	}
+}
+
+// Removes paths from the set
+func (p FieldPaths) Remove(paths ...string) {
+	for _, path := range paths {
+		delete(p, path)
+	}
+}
+
+// Returns whether the path is selected, either directly or by one of its parents ("address" selects "address.city")
+func (p FieldPaths) Has(path string) bool {
+	for {
+		if _, ok := p[path]; ok {
+			return true
+		}
+		idx := strings.LastIndexByte(path, '.')
+		if idx < 0 {
+			return false
+		}
+		path = path[:idx]
+	}
+}
+
+// Returns the paths of the set, sorted
+func (p FieldPaths) Paths() []string {
+	ret := make([]string, 0, len(p))
+	for path := range p {
+		ret = append(ret, path)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+//
+// Field mask helpers
+//
+
+// Error of an invalid field mask path
+type FieldMaskError struct {
+	Path    string
+	Message string
+}
+
+func NewFieldMaskError(path string, message string) *FieldMaskError {
+	return &FieldMaskError{
+		Path:    path,
+		Message: message,
+	}
+}
+
+func (e *FieldMaskError) Error() string {
+	return fmt.Sprintf("Invalid field mask path %q: %s", e.Path, e.Message)
+}
+
+// Prefixes the path of a field mask error returned for the sub paths of a field with the field name.
+// Other errors are returned unchanged.
+func PrefixFieldMaskError(field string, err error) error {
+	if fe, ok := err.(*FieldMaskError); ok {
+		return NewFieldMaskError(field+"."+fe.Path, fe.Message)
+	}
+	return err
+}
+
+// A field selected by a field mask
+type FieldMaskField struct {
+	// Proto name of the field
+	Name string
+	// Sub paths of the field, relative to it. Empty if the field is selected as a whole.
+	Paths []string
+}
+
+// Returns the error for sub paths of a field that have no sub fields
+func (f *FieldMaskField) NoSubfieldsError() error {
+	return NewFieldMaskError(f.Name+"."+f.Paths[0], "field "+f.Name+" has no sub fields")
+}
+
+// Groups field mask paths by their first field name, in the order they first appear. A field selected as a whole,
+// like "address" on ["address.city", "address"], has no sub paths.
+func SplitFieldMask(paths []string) ([]*FieldMaskField, error) {
+	var ret []*FieldMaskField
+	fields := make(map[string]*FieldMaskField)
+	whole := make(map[string]bool)
+
+	for _, path := range paths {
+		name, sub := path, ""
+		if idx := strings.IndexByte(path, '.'); idx >= 0 {
+			name, sub = path[:idx], path[idx+1:]
+			if sub == "" {
+				return nil, NewFieldMaskError(path, "empty field name")
+			}
+		}
+		if name == "" {
+			return nil, NewFieldMaskError(path, "empty field name")
+		}
+
+		f, ok := fields[name]
+		if !ok {
+			f = &FieldMaskField{Name: name}
+			fields[name] = f
+			ret = append(ret, f)
+		}
+
+		if sub == "" {
+			whole[name] = true
+			f.Paths = nil
+		} else if !whole[name] {
+			f.Paths = append(f.Paths, sub)
+		}
+	}
+
+	return ret, nil
+}
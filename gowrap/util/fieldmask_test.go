@@ -0,0 +1,98 @@
+package fproto_gowrap_util
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	field_mask "google.golang.org/genproto/protobuf/field_mask"
+)
+
+func TestFieldMaskRoundTrip(t *testing.T) {
+	src := &field_mask.FieldMask{Paths: []string{"name", "address.city"}}
+	if paths := ImportFieldMask(src); !reflect.DeepEqual(paths, src.Paths) {
+		t.Errorf("unexpected paths %v", paths)
+	}
+	if fm := ExportFieldMask(ImportFieldMask(src)); !reflect.DeepEqual(fm.Paths, src.Paths) {
+		t.Errorf("unexpected field mask %v", fm)
+	}
+
+	// nil is kept, an empty mask is not
+	if fm := ExportFieldMask(ImportFieldMask(nil)); fm != nil {
+		t.Errorf("expected a nil FieldMask, got %v", fm)
+	}
+	if fm := ExportFieldMask(ImportFieldMask(&field_mask.FieldMask{})); fm == nil || len(fm.Paths) != 0 {
+		t.Errorf("expected an empty FieldMask, got %v", fm)
+	}
+	if fm := ExportFieldPaths(ImportFieldPaths(nil)); fm != nil {
+		t.Errorf("expected a nil FieldMask, got %v", fm)
+	}
+}
+
+func TestFieldPaths(t *testing.T) {
+	p := ImportFieldPaths(&field_mask.FieldMask{Paths: []string{"name", "address", "items.price", "name"}})
+
+	tests := []struct {
+		path string
+		has  bool
+	}{
+		{"name", true},
+		{"address", true},
+		{"address.city", true},
+		{"address.city.code", true},
+		{"items", false},
+		{"items.price", true},
+		{"items.title", false},
+		{"names", false},
+	}
+	for _, test := range tests {
+		if has := p.Has(test.path); has != test.has {
+			t.Errorf("%s: expected Has %v", test.path, test.has)
+		}
+	}
+
+	p.Remove("address")
+	p.Add("id")
+	expected := []string{"id", "items.price", "name"}
+	if fm := ExportFieldPaths(p); !reflect.DeepEqual(fm.Paths, expected) {
+		t.Errorf("expected paths %v, got %v", expected, fm.Paths)
+	}
+}
+
+func TestSplitFieldMask(t *testing.T) {
+	fields, err := SplitFieldMask([]string{"address.city", "name", "address.zip", "items.price", "items", "items.title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*FieldMaskField{
+		{Name: "address", Paths: []string{"city", "zip"}},
+		{Name: "name"},
+		{Name: "items"},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("unexpected fields:")
+		for _, f := range fields {
+			t.Errorf("  %+v", f)
+		}
+	}
+
+	for _, path := range []string{"", ".name", "address."} {
+		_, err := SplitFieldMask([]string{"name", path})
+		if _, ok := err.(*FieldMaskError); !ok {
+			t.Errorf("%q: expected a field mask error, got %v", path, err)
+		}
+	}
+}
+
+func TestFieldMaskErrors(t *testing.T) {
+	f := &FieldMaskField{Name: "name", Paths: []string{"first"}}
+	err := PrefixFieldMaskError("user", f.NoSubfieldsError())
+	if err.Error() != `Invalid field mask path "user.name.first": field name has no sub fields` {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	other := errors.New("other")
+	if err := PrefixFieldMaskError("user", other); err != other {
+		t.Errorf("expected the error unchanged, got %v", err)
+	}
+}
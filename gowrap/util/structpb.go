@@ -0,0 +1,236 @@
+package fproto_gowrap_util
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	structpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+// Imports a google.protobuf.Struct as a map. A nil Struct is imported as a nil map.
+func ImportStruct(s *structpb.Struct) map[string]interface{} {
+	if s == nil {
+		return nil
+	}
+
+	ret := make(map[string]interface{}, len(s.Fields))
+	for k, v := range s.Fields {
+		ret[k] = ImportValue(v)
+	}
+	return ret
+}
+
+// Imports a google.protobuf.ListValue as a slice. A nil ListValue is imported as a nil slice.
+func ImportListValue(s *structpb.ListValue) []interface{} {
+	if s == nil {
+		return nil
+	}
+
+	ret := make([]interface{}, len(s.Values))
+	for i, v := range s.Values {
+		ret[i] = ImportValue(v)
+	}
+	return ret
+}
+
+// Imports a google.protobuf.Value as nil, float64, string, bool, map[string]interface{} or []interface{}.
+// Both a nil Value and a NullValue are imported as nil, and nil is exported back as a nil Value, except inside
+// maps and slices, where it is exported as a NullValue.
+func ImportValue(s *structpb.Value) interface{} {
+	if s == nil {
+		return nil
+	}
+
+	switch k := s.Kind.(type) {
+	case *structpb.Value_NumberValue:
+		return k.NumberValue
+	case *structpb.Value_StringValue:
+		return k.StringValue
+	case *structpb.Value_BoolValue:
+		return k.BoolValue
+	case *structpb.Value_StructValue:
+		ret := ImportStruct(k.StructValue)
+		if ret == nil {
+			ret = make(map[string]interface{})
+		}
+		return ret
+	case *structpb.Value_ListValue:
+		ret := ImportListValue(k.ListValue)
+		if ret == nil {
+			ret = make([]interface{}, 0)
+		}
+		return ret
+	}
+	return nil
+}
+
+// Exports a map as a google.protobuf.Struct. A nil map is exported as a nil Struct.
+// See ExportValue for the supported values.
+func ExportStruct(v map[string]interface{}) (*structpb.Struct, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return exportStruct(reflect.ValueOf(v), "", make(map[exportRef]bool))
+}
+
+// Exports a slice as a google.protobuf.ListValue. A nil slice is exported as a nil ListValue.
+// See ExportValue for the supported values.
+func ExportListValue(v []interface{}) (*structpb.ListValue, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return exportListValue(reflect.ValueOf(v), "", make(map[exportRef]bool))
+}
+
+// Exports a value as a google.protobuf.Value. A nil value is exported as a nil Value, so an unset field is kept
+// unset; nil elements of maps and slices are exported as a NullValue.
+// The supported values are nil, bools, strings, integers, finite floats, maps with string keys, and slices and
+// arrays, of any of the supported values. Pointers and interfaces are followed. Other values (like NaN, []byte,
+// channels, functions or maps that contain themselves) return an error with their path.
+func ExportValue(v interface{}) (*structpb.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return exportValue(reflect.ValueOf(v), "", make(map[exportRef]bool))
+}
+
+// A map, slice or pointer being exported, to detect the values that contain themselves
+type exportRef struct {
+	ptr uintptr
+	len int
+}
+
+// Marks the map, slice or pointer as being exported. Returns false if it already is, so the value contains itself.
+func exportEnter(v reflect.Value, visiting map[exportRef]bool) (exportRef, bool) {
+	ref := exportRef{ptr: v.Pointer(), len: -1}
+	if v.Kind() == reflect.Slice {
+		ref.len = v.Len()
+	}
+	if visiting[ref] {
+		return ref, false
+	}
+	visiting[ref] = true
+	return ref, true
+}
+
+func exportStruct(v reflect.Value, path string, visiting map[exportRef]bool) (*structpb.Struct, error) {
+	if v.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("Unsupported map key type %s for google.protobuf.Struct%s", v.Type().Key(), exportPath(path))
+	}
+
+	ref, ok := exportEnter(v, visiting)
+	if !ok {
+		return nil, fmt.Errorf("Map contains itself for google.protobuf.Struct%s", exportPath(path))
+	}
+	defer delete(visiting, ref)
+
+	// sort the keys for deterministic errors
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	ret := &structpb.Struct{
+		Fields: make(map[string]*structpb.Value, len(keys)),
+	}
+	for _, k := range keys {
+		fv, err := exportValue(v.MapIndex(k), fmt.Sprintf("%s[%q]", path, k.String()), visiting)
+		if err != nil {
+			return nil, err
+		}
+		ret.Fields[k.String()] = fv
+	}
+	return ret, nil
+}
+
+func exportListValue(v reflect.Value, path string, visiting map[exportRef]bool) (*structpb.ListValue, error) {
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, fmt.Errorf("Unsupported type %s for google.protobuf.ListValue%s", v.Type(), exportPath(path))
+	}
+
+	if v.Kind() == reflect.Slice {
+		ref, ok := exportEnter(v, visiting)
+		if !ok {
+			return nil, fmt.Errorf("Slice contains itself for google.protobuf.ListValue%s", exportPath(path))
+		}
+		defer delete(visiting, ref)
+	}
+
+	ret := &structpb.ListValue{
+		Values: make([]*structpb.Value, v.Len()),
+	}
+	for i := 0; i < v.Len(); i++ {
+		lv, err := exportValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), visiting)
+		if err != nil {
+			return nil, err
+		}
+		ret.Values[i] = lv
+	}
+	return ret, nil
+}
+
+func exportValue(v reflect.Value, path string, visiting map[exportRef]bool) (*structpb.Value, error) {
+	if !v.IsValid() {
+		return &structpb.Value{Kind: &structpb.Value_NullValue{}}, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return &structpb.Value{Kind: &structpb.Value_NullValue{}}, nil
+		}
+		if v.Kind() == reflect.Ptr {
+			ref, ok := exportEnter(v, visiting)
+			if !ok {
+				return nil, fmt.Errorf("Pointer to itself for google.protobuf.Value%s", exportPath(path))
+			}
+			defer delete(visiting, ref)
+		}
+		return exportValue(v.Elem(), path, visiting)
+	case reflect.Bool:
+		return &structpb.Value{Kind: &structpb.Value_BoolValue{BoolValue: v.Bool()}}, nil
+	case reflect.String:
+		return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: v.String()}}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: float64(v.Int())}}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: float64(v.Uint())}}, nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("Unsupported number %v for google.protobuf.Value%s", f, exportPath(path))
+		}
+		return &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: f}}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return &structpb.Value{Kind: &structpb.Value_NullValue{}}, nil
+		}
+		s, err := exportStruct(v, path, visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &structpb.Value{Kind: &structpb.Value_StructValue{StructValue: s}}, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return &structpb.Value{Kind: &structpb.Value_NullValue{}}, nil
+		}
+		fallthrough
+	case reflect.Array:
+		l, err := exportListValue(v, path, visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &structpb.Value{Kind: &structpb.Value_ListValue{ListValue: l}}, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported type %s for google.protobuf.Value%s", v.Type(), exportPath(path))
+}
+
+func exportPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return " at " + path
+}
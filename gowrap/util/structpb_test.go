@@ -0,0 +1,122 @@
+package fproto_gowrap_util
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+func TestValueNilRoundTrip(t *testing.T) {
+	// an unset field stays unset
+	v, err := ExportValue(ImportValue(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != nil {
+		t.Errorf("expected a nil Value, got %v", v)
+	}
+
+	// a null element of a list stays null
+	src := &structpb.Value{Kind: &structpb.Value_ListValue{ListValue: &structpb.ListValue{
+		Values: []*structpb.Value{
+			{Kind: &structpb.Value_NullValue{}},
+			{Kind: &structpb.Value_StringValue{StringValue: "a"}},
+		},
+	}}}
+	v, err = ExportValue(ImportValue(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(v, src) {
+		t.Errorf("expected %v, got %v", src, v)
+	}
+}
+
+func TestStructRoundTrip(t *testing.T) {
+	src := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"null":   {Kind: &structpb.Value_NullValue{}},
+		"number": {Kind: &structpb.Value_NumberValue{NumberValue: 1.5}},
+		"string": {Kind: &structpb.Value_StringValue{StringValue: "s"}},
+		"bool":   {Kind: &structpb.Value_BoolValue{BoolValue: true}},
+		"struct": {Kind: &structpb.Value_StructValue{StructValue: &structpb.Struct{Fields: map[string]*structpb.Value{}}}},
+		"list":   {Kind: &structpb.Value_ListValue{ListValue: &structpb.ListValue{Values: []*structpb.Value{}}}},
+	}}
+
+	imported := ImportStruct(src)
+	expected := map[string]interface{}{
+		"null":   nil,
+		"number": 1.5,
+		"string": "s",
+		"bool":   true,
+		"struct": map[string]interface{}{},
+		"list":   []interface{}{},
+	}
+	if !reflect.DeepEqual(imported, expected) {
+		t.Errorf("expected %v, got %v", expected, imported)
+	}
+
+	exported, err := ExportStruct(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(exported, src) {
+		t.Errorf("expected %v, got %v", src, exported)
+	}
+
+	if s, err := ExportStruct(ImportStruct(nil)); s != nil || err != nil {
+		t.Errorf("expected a nil Struct, got %v, %v", s, err)
+	}
+	if l, err := ExportListValue(ImportListValue(nil)); l != nil || err != nil {
+		t.Errorf("expected a nil ListValue, got %v, %v", l, err)
+	}
+}
+
+func TestExportValueConversions(t *testing.T) {
+	n := 3
+	v, err := ExportValue(map[string]interface{}{
+		"int":     int64(2),
+		"pointer": &n,
+		"array":   [2]string{"a", "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"int":     2.0,
+		"pointer": 3.0,
+		"array":   []interface{}{"a", "b"},
+	}
+	if imported := ImportValue(v); !reflect.DeepEqual(imported, expected) {
+		t.Errorf("expected %v, got %v", expected, imported)
+	}
+}
+
+func TestExportValueErrors(t *testing.T) {
+	cyclic := map[string]interface{}{}
+	cyclic["self"] = cyclic
+
+	tests := []struct {
+		name  string
+		value interface{}
+		err   string
+	}{
+		{"nan", []interface{}{1, math.NaN()}, "Unsupported number NaN for google.protobuf.Value at [1]"},
+		{"bytes", map[string]interface{}{"b": []byte("x")}, `Unsupported type []uint8 for google.protobuf.ListValue at ["b"]`},
+		{"key", map[int]interface{}{1: "a"}, "Unsupported map key type int"},
+		{"channel", make(chan int), "Unsupported type chan int"},
+		{"cyclic", cyclic, `Map contains itself for google.protobuf.Struct at ["self"]`},
+	}
+
+	for _, test := range tests {
+		_, err := ExportValue(test.value)
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		} else if !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package fproto_gowrap_util
+
+// Unknown fields of a wrapped message, in protobuf wire format.
+// Fields received from a newer version of the proto file are kept here, so they are not lost when the message
+// is exported again.
+type UnknownFields []byte
+
+// Imports the unknown fields of a protoc-gen-go generated message, from its XXX_unrecognized field
+func ImportUnknownFields(s []byte) UnknownFields {
+	if len(s) == 0 {
+		return nil
+	}
+	return append(UnknownFields(nil), s...)
+}
+
+// Exports the unknown fields, to the XXX_unrecognized field of a protoc-gen-go generated message
+func (u UnknownFields) Export() []byte {
+	if len(u) == 0 {
+		return nil
+	}
+	return append([]byte(nil), u...)
+}
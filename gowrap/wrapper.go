@@ -2,8 +2,10 @@ package fproto_gowrap
 
 import (
 	"errors"
+	"sync"
 
 	"github.com/RangelReale/fdep"
+	"github.com/RangelReale/fproto-wrap"
 )
 
 type WrapperFile struct {
@@ -21,6 +23,54 @@ type Wrapper struct {
 	ServiceGen     ServiceGen
 	Customizers    []Customizer
 	Files          []*WrapperFile
+
+	// Order in which the owned files are generated, and listed on Generator.OwnedFiles
+	FileOrder fproto_wrap.FileOrder
+
+	// Number of files to generate in parallel. If 0 or 1, the files are generated sequentially.
+	// The generated files are always written to the FileOutput sequentially, in FileOrder order.
+	//
+	// When greater than 1, the methods of PkgSource, TypeConverters, ServiceGen and Customizers are called
+	// concurrently from multiple goroutines, so they must be safe for concurrent use. Each file uses its own
+	// Generator and GeneratorFiles, so plugins that don't keep state of their own between calls are safe.
+	// Customizer_Global is always called sequentially, after all files were generated.
+	Concurrency int
+
+	// Keeps the unknown fields of the source messages on the wrapped structs, in a XXX_unrecognized field,
+	// and restores them on Export(), so messages from newer proto versions can pass through unchanged.
+	KeepUnknownFields bool
+
+	// Generates the enums as new types instead of aliases to the source enums, with String, IsValid,
+	// MarshalText and UnmarshalText methods, and Parse<Enum> and <Enum>_Values functions.
+	EnumWrap bool
+
+	// How collisions between the generated Go identifiers are resolved. By default the generation fails.
+	NameCollision NameCollision
+
+	// Writes a side-car source map (".map.json") next to each generated file, linking its line ranges to the
+	// proto elements they were generated from.
+	SourceMap bool
+
+	// Adds a comment with the proto file position before the code of each message, enum, service and extension.
+	PositionComments bool
+
+	// Positions of the proto elements on their files, reported on GenerationError, the source maps and the
+	// position comments. If nil, only the file and the element path are reported.
+	Positions PositionSource
+
+	// Also wraps the messages of the imported (DepType_Imported) files used by the owned files, generating them
+	// into their own wrap packages, so the type converters apply to them too. The well-known types files are
+	// never wrapped.
+	WrapImported bool
+
+	// Proto file path => Go wrap package of files that were wrapped outside of this run, like a shared proto
+	// library. The messages of these files are converted using the wrap package, but they are not generated.
+	ExternalWrap map[string]string
+
+	// Incremental generation cache. If set, and the output implements FileOutput_Keep, the owned files whose
+	// inputs didn't change since the previous run are kept instead of generated again.
+	// Customizer_Global is always called.
+	Cache *Cache
 }
 
 // Creates a new wrapper
@@ -37,14 +87,24 @@ func (wp *Wrapper) GenerateFile(filename string, output FileOutput) error {
 		return errors.New("File not found")
 	}
 
-	g, err := NewGenerator(wp.dep, df)
-	g.PkgSource = wp.PkgSource
-	g.TypeConverters = wp.TypeConverters
-	g.ServiceGen = wp.ServiceGen
-	g.Customizers = wp.Customizers
+	files, err := wp.generatedFiles()
+	if err != nil {
+		return err
+	}
+
+	names, err := wp.buildNames(files)
+	if err != nil {
+		return err
+	}
+
+	g, err := wp.newFileGenerator(df, files, names)
 	if err != nil {
 		return err
 	}
+	if g == nil {
+		// not generated, like imported files without WrapImported
+		return nil
+	}
 
 	err = g.Generate()
 	if err != nil {
@@ -52,12 +112,10 @@ func (wp *Wrapper) GenerateFile(filename string, output FileOutput) error {
 	}
 
 	// write all files
-	for _, gf := range g.Files {
-		if gf != nil && gf.HaveData() {
-			err = output.Output(gf)
-			if err != nil {
-				return err
-			}
+	for _, gf := range g.DataFiles() {
+		err = output.Output(gf)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -65,48 +123,110 @@ func (wp *Wrapper) GenerateFile(filename string, output FileOutput) error {
 }
 
 // Generates all owned files.
+// The output is only finalized if the generation succeeds.
 func (wp *Wrapper) Generate(output FileOutput) error {
-	output.Initialize()
-	defer output.Finalize()
+	err := output.Initialize()
+	if err != nil {
+		return err
+	}
+
+	err = wp.generate(output)
+	if err != nil {
+		return err
+	}
+
+	err = output.Finalize()
+	if err != nil {
+		return err
+	}
+
+	if wp.useCache(output) {
+		return wp.Cache.Save()
+	}
 
-	for _, df := range wp.dep.Files {
-		if df.DepType == fdep.DepType_Own {
-			g, err := NewGenerator(wp.dep, df)
+	return nil
+}
+
+// Checks if the incremental generation cache can be used with the output
+func (wp *Wrapper) useCache(output FileOutput) bool {
+	if wp.Cache == nil {
+		return false
+	}
+	_, ok := output.(FileOutput_Keep)
+	return ok
+}
+
+func (wp *Wrapper) generate(output FileOutput) error {
+	files, err := wp.generatedFiles()
+	if err != nil {
+		return err
+	}
+
+	names, err := wp.buildNames(files)
+	if err != nil {
+		return err
+	}
+
+	// skip the files that didn't change since the previous run
+	gen := files
+	hashes := make(map[string]string)
+	if wp.useCache(output) {
+		err = wp.Cache.Load()
+		if err != nil {
+			return err
+		}
+
+		gen = nil
+		for _, df := range files {
+			hash := wp.cacheHash(df, files, names)
+
+			kept, err := wp.keepCached(df, hash, output.(FileOutput_Keep))
 			if err != nil {
 				return err
 			}
-
-			if !g.IsFileWrap(df) {
-				continue
+			if !kept {
+				hashes[df.FilePath] = hash
+				gen = append(gen, df)
 			}
+		}
+	}
 
-			g.PkgSource = wp.PkgSource
-			g.TypeConverters = wp.TypeConverters
-			g.ServiceGen = wp.ServiceGen
-			g.Customizers = wp.Customizers
-			for _, f := range wp.Files {
-				if f.FileAlias != "" {
-					g.SetFileAlias(f.FileId, f.FileAlias)
-				} else {
-					g.SetFile(f.FileId, f.Suffix)
-				}
+	// write all files of one proto file
+	write := func(df *fdep.DepFile, gfiles []*GeneratorFile) error {
+		var outputs []string
+		for _, gf := range gfiles {
+			err := output.Output(gf)
+			if err != nil {
+				return err
+			}
+			outputs = append(outputs, gf.Filename())
+			if gf.SourceMap {
+				outputs = append(outputs, gf.SourceMapFilename())
 			}
+		}
 
-			err = g.Generate()
+		if hash, ok := hashes[df.FilePath]; ok {
+			wp.Cache.Set(df.FilePath, &CacheEntry{Hash: hash, Outputs: outputs})
+		}
+		return nil
+	}
+
+	if wp.Concurrency > 1 {
+		err := wp.generateParallel(gen, files, names, write)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, df := range gen {
+			gfiles, err := wp.generateDepFile(df, files, names)
 			if err != nil {
 				return err
 			}
 
-			// write all files
-			for _, gf := range g.Files {
-				if gf != nil && gf.HaveData() {
-					err = output.Output(gf)
-					if err != nil {
-						return err
-					}
-				}
+			err = write(df, gfiles)
+			if err != nil {
+				return err
 			}
-
 		}
 	}
 
@@ -118,9 +238,16 @@ func (wp *Wrapper) Generate(output FileOutput) error {
 				return err
 			}
 
+			g.OwnedFiles = files
 			g.PkgSource = wp.PkgSource
 			g.TypeConverters = wp.TypeConverters
 			g.ServiceGen = wp.ServiceGen
+			g.KeepUnknownFields = wp.KeepUnknownFields
+			g.EnumWrap = wp.EnumWrap
+			g.WrapImported = wp.WrapImported
+			g.ExternalWrap = wp.ExternalWrap
+			g.Names = names
+			wp.setSourceMap(g)
 			/*
 				g.Customizers = wp.Customizers
 				for _, f := range wp.Files {
@@ -138,12 +265,10 @@ func (wp *Wrapper) Generate(output FileOutput) error {
 			}
 
 			// write all files
-			for _, gf := range g.Files {
-				if gf != nil && gf.HaveData() {
-					err = output.Output(gf)
-					if err != nil {
-						return err
-					}
+			for _, gf := range g.DataFiles() {
+				err = output.Output(gf)
+				if err != nil {
+					return err
 				}
 			}
 		}
@@ -152,6 +277,222 @@ func (wp *Wrapper) Generate(output FileOutput) error {
 	return nil
 }
 
+// Keeps the outputs of the file from the previous run if its hash didn't change
+func (wp *Wrapper) keepCached(df *fdep.DepFile, hash string, keep FileOutput_Keep) (bool, error) {
+	entry, ok := wp.Cache.Get(df.FilePath)
+	if !ok || entry.Hash != hash {
+		return false, nil
+	}
+
+	for _, fn := range entry.Outputs {
+		kept, err := keep.Keep(fn)
+		if err != nil {
+			return false, err
+		}
+		if !kept {
+			return false, nil
+		}
+	}
+
+	wp.Cache.Set(df.FilePath, entry)
+	return true, nil
+}
+
+// Generates the files using a pool of Concurrency workers, and writes them in the original order.
+func (wp *Wrapper) generateParallel(files []*fdep.DepFile, ownedFiles []*fdep.DepFile, names *Names, write func(df *fdep.DepFile, gfiles []*GeneratorFile) error) error {
+	type result struct {
+		files []*GeneratorFile
+		err   error
+	}
+
+	results := make([]result, len(files))
+
+	var failedLock sync.Mutex
+	failed := false
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < wp.Concurrency && w < len(files); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// skip the remaining files after an error
+				failedLock.Lock()
+				skip := failed
+				failedLock.Unlock()
+				if skip {
+					continue
+				}
+
+				results[i].files, results[i].err = wp.generateDepFile(files[i], ownedFiles, names)
+				if results[i].err != nil {
+					failedLock.Lock()
+					failed = true
+					failedLock.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// report the error of the first file in order
+	for _, r := range results {
+		if r.err != nil {
+			return r.err
+		}
+	}
+
+	// write all files
+	for i, r := range results {
+		err := write(files[i], r.files)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Collects the Go identifiers of the owned files, resolving collisions
+func (wp *Wrapper) buildNames(files []*fdep.DepFile) (*Names, error) {
+	var entries []*nameEntry
+	for _, df := range files {
+		g, err := wp.newFileGenerator(df, files, nil)
+		if err != nil {
+			return nil, err
+		}
+		if g == nil {
+			continue
+		}
+
+		fentries, err := g.nameEntries()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fentries...)
+	}
+
+	return newNames(entries, wp.NameCollision)
+}
+
+// Creates the generator of an owned file, or nil if the file is not generated.
+func (wp *Wrapper) newFileGenerator(df *fdep.DepFile, ownedFiles []*fdep.DepFile, names *Names) (*Generator, error) {
+	g, err := NewGenerator(wp.dep, df)
+	if err != nil {
+		return nil, err
+	}
+
+	g.WrapImported = wp.WrapImported
+	g.ExternalWrap = wp.ExternalWrap
+	if !g.IsFileGenerate(df) {
+		return nil, nil
+	}
+
+	g.OwnedFiles = ownedFiles
+	g.PkgSource = wp.PkgSource
+	g.TypeConverters = wp.TypeConverters
+	g.ServiceGen = wp.ServiceGen
+	g.Customizers = wp.Customizers
+	g.KeepUnknownFields = wp.KeepUnknownFields
+	g.EnumWrap = wp.EnumWrap
+	g.Names = names
+	for _, f := range wp.Files {
+		if f.FileAlias != "" {
+			g.SetFileAlias(f.FileId, f.FileAlias)
+		} else {
+			g.SetFile(f.FileId, f.Suffix)
+		}
+	}
+	wp.setSourceMap(g)
+
+	return g, nil
+}
+
+// Returns the files to generate, in FileOrder order: the owned files and, if WrapImported is set, the imported
+// files they depend on, directly or transitively. Files with an external wrap package are not generated.
+func (wp *Wrapper) generatedFiles() ([]*fdep.DepFile, error) {
+	owned, err := fproto_wrap.OwnedFiles(wp.dep, fproto_wrap.FILEORDER_PATH)
+	if err != nil {
+		return nil, err
+	}
+	if !wp.WrapImported {
+		return fproto_wrap.OrderFiles(wp.dep, owned, wp.FileOrder)
+	}
+
+	var files []*fdep.DepFile
+	found := make(map[string]bool)
+	var collect func(df *fdep.DepFile)
+	collect = func(df *fdep.DepFile) {
+		if found[df.FilePath] {
+			return
+		}
+		found[df.FilePath] = true
+
+		if _, ok := wp.ExternalWrap[df.FilePath]; ok {
+			return
+		}
+		if df.DepType != fdep.DepType_Own && !IsImportWrappable(df) {
+			return
+		}
+		files = append(files, df)
+
+		for _, imp := range fproto_wrap.FileImports(df) {
+			if impf, ok := wp.dep.Files[imp]; ok {
+				collect(impf)
+			}
+		}
+	}
+	for _, df := range owned {
+		collect(df)
+	}
+
+	return fproto_wrap.OrderFiles(wp.dep, files, wp.FileOrder)
+}
+
+// Sets the source map and position options on the generator and on its files
+func (wp *Wrapper) setSourceMap(g *Generator) {
+	g.SourceMap = wp.SourceMap
+	g.PositionComments = wp.PositionComments
+	g.Positions = wp.Positions
+	for _, gf := range g.Files {
+		gf.SourceMap = wp.SourceMap
+		gf.PositionComments = wp.PositionComments
+	}
+}
+
+// Generates one owned file, returning the generated files already formatted, in file id order.
+func (wp *Wrapper) generateDepFile(df *fdep.DepFile, ownedFiles []*fdep.DepFile, names *Names) ([]*GeneratorFile, error) {
+	g, err := wp.newFileGenerator(df, ownedFiles, names)
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, nil
+	}
+
+	err = g.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := g.DataFiles()
+	for _, gf := range ret {
+		// format here, so it also runs in parallel
+		_, err = gf.Format()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
 // Generates all owned files.
 func (wp *Wrapper) GenerateFiles(outputpath string) error {
 	output := NewFileOutput_Default(outputpath)
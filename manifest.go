@@ -0,0 +1,159 @@
+package fproto_wrap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Number of bytes from the start of a file that are searched for the generated marker
+const manifestMarkerSearchSize = 1024
+
+// List of the files generated in a run, saved in the output path.
+// On the next run, files listed in the previous manifest that were not generated again are stale and can be removed.
+type Manifest struct {
+	// Output root path, all filenames are relative to it
+	OutputPath string
+
+	// Manifest filename, relative to OutputPath
+	Filename string
+
+	// Text that must be present at the start of a file for it to be removed as stale
+	Marker string
+
+	previous []string
+	current  map[string]bool
+}
+
+// Creates a new manifest
+func NewManifest(outputPath string, filename string, marker string) *Manifest {
+	return &Manifest{
+		OutputPath: outputPath,
+		Filename:   filename,
+		Marker:     marker,
+		current:    make(map[string]bool),
+	}
+}
+
+// Loads the previous manifest, if it exists
+func (m *Manifest) Load() error {
+	m.previous = nil
+
+	file, err := os.Open(filepath.Join(m.OutputPath, m.Filename))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	s := bufio.NewScanner(file)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m.previous = append(m.previous, line)
+	}
+	return s.Err()
+}
+
+// Adds a generated file
+func (m *Manifest) Add(filename string) {
+	m.current[filename] = true
+}
+
+// Returns the files listed on the previous manifest
+func (m *Manifest) Previous() []string {
+	return m.previous
+}
+
+// Returns the files generated on this run, in ascending order
+func (m *Manifest) Current() []string {
+	var ret []string
+	for fn := range m.current {
+		ret = append(ret, fn)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Returns the files listed on the previous manifest that were not generated on this run
+func (m *Manifest) Stale() []string {
+	var ret []string
+	for _, fn := range m.previous {
+		if !m.current[fn] {
+			ret = append(ret, fn)
+		}
+	}
+	return ret
+}
+
+// Removes the stale files. Files that don't exist anymore or that don't contain the marker are skipped.
+// Directories left empty are also removed.
+func (m *Manifest) RemoveStale() error {
+	for _, fn := range m.Stale() {
+		// never remove anything outside the output path
+		if strings.HasPrefix(filepath.Clean(filepath.FromSlash(fn)), "..") || filepath.IsAbs(filepath.FromSlash(fn)) {
+			continue
+		}
+
+		p := filepath.Join(m.OutputPath, filepath.FromSlash(fn))
+
+		isgen, err := m.IsGenerated(p)
+		if err != nil {
+			return err
+		}
+		if !isgen {
+			continue
+		}
+
+		err = os.Remove(p)
+		if err != nil {
+			return err
+		}
+
+		// remove empty directories up to the output path
+		for dir := filepath.Dir(p); dir != filepath.Clean(m.OutputPath) && dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Checks if the file exists and contains the marker at its start
+func (m *Manifest) IsGenerated(p string) (bool, error) {
+	file, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	head, err := ioutil.ReadAll(io.LimitReader(file, manifestMarkerSearchSize))
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Contains(head, []byte(m.Marker)), nil
+}
+
+// Saves the manifest with the files generated on this run
+func (m *Manifest) Save() error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s. DO NOT EDIT.\n", m.Marker)
+	for _, fn := range m.Current() {
+		fmt.Fprintln(&b, fn)
+	}
+
+	return ioutil.WriteFile(filepath.Join(m.OutputPath, m.Filename), b.Bytes(), 0644)
+}
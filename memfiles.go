@@ -0,0 +1,66 @@
+package fproto_wrap
+
+import "sort"
+
+// Generated files kept in memory, keyed by filename. Used by the memory file outputs of the wrappers.
+type MemoryFiles struct {
+	files map[string][]byte
+}
+
+// Creates a new empty file set
+func NewMemoryFiles() *MemoryFiles {
+	return &MemoryFiles{
+		files: make(map[string][]byte),
+	}
+}
+
+// Removes all files
+func (f *MemoryFiles) Reset() {
+	f.files = make(map[string][]byte)
+}
+
+// Sets the contents of a file
+func (f *MemoryFiles) Set(filename string, content []byte) {
+	f.files[filename] = content
+}
+
+// Returns the number of generated files
+func (f *MemoryFiles) Len() int {
+	return len(f.files)
+}
+
+// Returns the generated filenames in ascending order
+func (f *MemoryFiles) Filenames() []string {
+	ret := make([]string, 0, len(f.files))
+	for fn := range f.files {
+		ret = append(ret, fn)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Returns the contents of a generated file
+func (f *MemoryFiles) Get(filename string) ([]byte, bool) {
+	content, ok := f.files[filename]
+	return content, ok
+}
+
+// Calls fn for each generated file, in ascending filename order. Stops at the first error.
+func (f *MemoryFiles) Each(fn func(filename string, content []byte) error) error {
+	for _, filename := range f.Filenames() {
+		err := fn(filename, f.files[filename])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns a copy of all generated files, keyed by filename
+func (f *MemoryFiles) Files() map[string][]byte {
+	ret := make(map[string][]byte, len(f.files))
+	for fn, content := range f.files {
+		ret[fn] = content
+	}
+	return ret
+}
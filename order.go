@@ -0,0 +1,102 @@
+package fproto_wrap
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/RangelReale/fdep"
+)
+
+// Order in which the owned files are generated
+type FileOrder int
+
+const (
+	// Ascending file path
+	FILEORDER_PATH FileOrder = iota
+	// Dependencies before the files that import them, ties in ascending file path
+	FILEORDER_DEPENDENCY
+)
+
+// Returns the files with the DepType_Own dep type, in the requested order.
+func OwnedFiles(dep *fdep.Dep, order FileOrder) ([]*fdep.DepFile, error) {
+	var files []*fdep.DepFile
+	for _, df := range dep.Files {
+		if df.DepType == fdep.DepType_Own {
+			files = append(files, df)
+		}
+	}
+	return OrderFiles(dep, files, order)
+}
+
+// Returns the files in the requested order.
+func OrderFiles(dep *fdep.Dep, files []*fdep.DepFile, order FileOrder) ([]*fdep.DepFile, error) {
+	files = append([]*fdep.DepFile(nil), files...)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].FilePath < files[j].FilePath
+	})
+
+	switch order {
+	case FILEORDER_PATH:
+		return files, nil
+	case FILEORDER_DEPENDENCY:
+		return dependencyOrder(dep, files)
+	}
+	return nil, fmt.Errorf("Unknown file order: %d", order)
+}
+
+// Topological sort of the files by their imports. Imports of files that are not in the list are ignored.
+func dependencyOrder(dep *fdep.Dep, files []*fdep.DepFile) ([]*fdep.DepFile, error) {
+	infiles := make(map[string]bool)
+	for _, df := range files {
+		infiles[df.FilePath] = true
+	}
+
+	var ret []*fdep.DepFile
+	done := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var visit func(df *fdep.DepFile) error
+	visit = func(df *fdep.DepFile) error {
+		if done[df.FilePath] {
+			return nil
+		}
+		if visiting[df.FilePath] {
+			return fmt.Errorf("Import cycle detected on file %s", df.FilePath)
+		}
+		visiting[df.FilePath] = true
+
+		for _, imp := range FileImports(df) {
+			if !infiles[imp] {
+				continue
+			}
+			err := visit(dep.Files[imp])
+			if err != nil {
+				return err
+			}
+		}
+
+		visiting[df.FilePath] = false
+		done[df.FilePath] = true
+		ret = append(ret, df)
+		return nil
+	}
+
+	for _, df := range files {
+		err := visit(df)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// Returns the file paths imported by the file, including the public and weak imports, in ascending order
+func FileImports(df *fdep.DepFile) []string {
+	var imports []string
+	imports = append(imports, df.ProtoFile.Dependencies...)
+	imports = append(imports, df.ProtoFile.PublicDependencies...)
+	imports = append(imports, df.ProtoFile.WeakDependencies...)
+	sort.Strings(imports)
+	return imports
+}
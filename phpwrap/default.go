@@ -1,10 +1,12 @@
 package fproto_phpwrap
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 
 	"github.com/RangelReale/fdep"
+	"github.com/RangelReale/fproto-wrap"
 )
 
 //
@@ -220,22 +222,49 @@ func (c *wrapCustomizers) GenerateClassCode(g *Generator, tp *fdep.DepType) erro
 // FileOutput: Default
 //
 
+// Name of the manifest file written in the output path
+const FILEOUTPUT_MANIFEST = ".fproto-phpwrap.manifest"
+
+// Writes the generated files to disk.
+// A manifest of the generated files is saved on the output path, and the files from the previous
+// run that were not generated again are removed.
 type FileOutput_Default struct {
 	OutputPath string
+
+	// Set to false to disable the manifest and the stale files removal
+	Manifest bool
+
+	manifest *fproto_wrap.Manifest
 }
 
 func NewFileOutput_Default(outputPath string) *FileOutput_Default {
 	return &FileOutput_Default{
 		OutputPath: outputPath,
+		Manifest:   true,
 	}
 }
 
 func (f *FileOutput_Default) Initialize() error {
-	return nil
+	f.manifest = nil
+	if !f.Manifest {
+		return nil
+	}
+
+	f.manifest = fproto_wrap.NewManifest(f.OutputPath, FILEOUTPUT_MANIFEST, GENERATED_MARKER)
+	return f.manifest.Load()
 }
 
 func (f *FileOutput_Default) Finalize() error {
-	return nil
+	if f.manifest == nil {
+		return nil
+	}
+
+	err := f.manifest.RemoveStale()
+	if err != nil {
+		return err
+	}
+
+	return f.manifest.Save()
 }
 
 func (f *FileOutput_Default) Output(g *GeneratorFile) error {
@@ -260,5 +289,48 @@ func (f *FileOutput_Default) Output(g *GeneratorFile) error {
 		return err
 	}
 
+	if f.manifest != nil {
+		f.manifest.Add(g.Filename())
+	}
+
+	return nil
+}
+
+//
+// FileOutput: memory
+//
+
+// Collects the generated files in memory, keyed by filename, without touching the filesystem.
+// The files of the previous run are removed on Initialize.
+type FileOutput_Memory struct {
+	*fproto_wrap.MemoryFiles
+}
+
+func NewFileOutput_Memory() *FileOutput_Memory {
+	return &FileOutput_Memory{
+		MemoryFiles: fproto_wrap.NewMemoryFiles(),
+	}
+}
+
+func (f *FileOutput_Memory) Initialize() error {
+	f.Reset()
+	return nil
+}
+
+func (f *FileOutput_Memory) Finalize() error {
+	return nil
+}
+
+func (f *FileOutput_Memory) Output(g *GeneratorFile) error {
+	var b bytes.Buffer
+
+	// output contents
+	err := g.Output(&b)
+	if err != nil {
+		return err
+	}
+
+	f.Set(g.Filename(), b.Bytes())
+
 	return nil
 }
@@ -1,7 +1,13 @@
 package fproto_phpwrap
 
+// Interface to write the generated files
 type FileOutput interface {
+	// Called before the generation starts
 	Initialize() error
+
+	// Called after all the files were successfully generated
 	Finalize() error
+
+	// Outputs one generated file
 	Output(g *GeneratorFile) error
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/RangelReale/fdep"
@@ -40,6 +41,9 @@ type Generator struct {
 
 	// Customizers
 	Customizers []Customizer
+
+	// Owned files of the current Wrapper run, in generation order
+	OwnedFiles []*fdep.DepFile
 }
 
 // Creates a new generator for the file path.
@@ -64,6 +68,23 @@ func (g *Generator) SetFile(fileId string) {
 	g.Files[fileId] = NewGeneratorFile(g, fileId)
 }
 
+// Returns the files that have data, in file id order
+func (g *Generator) DataFiles() []*GeneratorFile {
+	var fileIds []string
+	for fileId, gf := range g.Files {
+		if gf != nil && gf.HaveData() {
+			fileIds = append(fileIds, fileId)
+		}
+	}
+	sort.Strings(fileIds)
+
+	var ret []*GeneratorFile
+	for _, fileId := range fileIds {
+		ret = append(ret, g.Files[fileId])
+	}
+	return ret
+}
+
 // Gets a file by id
 func (g *Generator) F(fileId string) *GeneratorFile {
 	if gf, ok := g.Files[fileId]; ok {
@@ -9,6 +9,9 @@ import (
 	"github.com/RangelReale/fproto"
 )
 
+// Marker present on the header of all generated files
+const GENERATED_MARKER = "Code generated by fproto-phpwrap"
+
 // A single generated output file
 type GeneratorFile struct {
 	generator *Generator
@@ -141,6 +144,10 @@ func (g *GeneratorFile) generateHeader() {
 	_, wrapNS, _ := g.G().PhpWrapNS(g.G().GetDepFile())
 
 	g.P("<?php")
+	g.P("// ", GENERATED_MARKER, ". DO NOT EDIT.")
+	if g.G().GetDepFile() != nil {
+		g.P("// source file: ", g.G().GetDepFile().FilePath)
+	}
 	g.P("namespace ", wrapNS, ";")
 	g.P()
 }
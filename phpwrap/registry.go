@@ -0,0 +1,105 @@
+package fproto_phpwrap
+
+import "github.com/RangelReale/fproto-wrap"
+
+// Creates a type converter plugin from a string-keyed option map
+type TypeConverterPluginFactory func(options map[string]string) (TypeConverterPlugin, error)
+
+// Creates a customizer from a string-keyed option map
+type CustomizerFactory func(options map[string]string) (Customizer, error)
+
+// Creates a service generator from a string-keyed option map
+type ServiceGenFactory func(options map[string]string) (ServiceGen, error)
+
+var (
+	registryTypeConverters = fproto_wrap.NewRegistry("type converter plugin")
+	registryCustomizers    = fproto_wrap.NewRegistry("customizer")
+	registryServiceGens    = fproto_wrap.NewRegistry("service generator")
+)
+
+// Registers a type converter plugin by name, usually on the plugin package init function.
+// Panics if the name is already registered.
+func RegisterTypeConverterPlugin(name string, factory TypeConverterPluginFactory) {
+	registryTypeConverters.Register(name, factory)
+}
+
+// Registers a customizer by name, usually on the plugin package init function.
+// Panics if the name is already registered.
+func RegisterCustomizer(name string, factory CustomizerFactory) {
+	registryCustomizers.Register(name, factory)
+}
+
+// Registers a service generator by name, usually on the plugin package init function.
+// Panics if the name is already registered.
+func RegisterServiceGen(name string, factory ServiceGenFactory) {
+	registryServiceGens.Register(name, factory)
+}
+
+// Creates a registered type converter plugin
+func NewTypeConverterPlugin(name string, options map[string]string) (TypeConverterPlugin, error) {
+	factory, err := registryTypeConverters.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return factory.(TypeConverterPluginFactory)(options)
+}
+
+// Creates a registered customizer
+func NewCustomizer(name string, options map[string]string) (Customizer, error) {
+	factory, err := registryCustomizers.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return factory.(CustomizerFactory)(options)
+}
+
+// Creates a registered service generator
+func NewServiceGen(name string, options map[string]string) (ServiceGen, error) {
+	factory, err := registryServiceGens.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return factory.(ServiceGenFactory)(options)
+}
+
+// Returns the factory of a registered type converter plugin
+func LookupTypeConverterPlugin(name string) (TypeConverterPluginFactory, bool) {
+	factory, ok := registryTypeConverters.Lookup(name)
+	if !ok {
+		return nil, false
+	}
+	return factory.(TypeConverterPluginFactory), true
+}
+
+// Returns the factory of a registered customizer
+func LookupCustomizer(name string) (CustomizerFactory, bool) {
+	factory, ok := registryCustomizers.Lookup(name)
+	if !ok {
+		return nil, false
+	}
+	return factory.(CustomizerFactory), true
+}
+
+// Returns the factory of a registered service generator
+func LookupServiceGen(name string) (ServiceGenFactory, bool) {
+	factory, ok := registryServiceGens.Lookup(name)
+	if !ok {
+		return nil, false
+	}
+	return factory.(ServiceGenFactory), true
+}
+
+// Lists the registered type converter plugin names, in ascending order
+func ListTypeConverterPlugins() []string {
+	return registryTypeConverters.List()
+}
+
+// Lists the registered customizer names, in ascending order
+func ListCustomizers() []string {
+	return registryCustomizers.List()
+}
+
+// Lists the registered service generator names, in ascending order
+func ListServiceGens() []string {
+	return registryServiceGens.List()
+}
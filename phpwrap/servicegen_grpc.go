@@ -17,6 +17,12 @@ func NewServiceGen_gRPC() *ServiceGen_gRPC {
 	return &ServiceGen_gRPC{}
 }
 
+func init() {
+	RegisterServiceGen("grpc", func(options map[string]string) (ServiceGen, error) {
+		return NewServiceGen_gRPC(), nil
+	})
+}
+
 func (s *ServiceGen_gRPC) ServiceType() string {
 	return "grpc"
 }
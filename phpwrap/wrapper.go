@@ -1,7 +1,10 @@
 package fproto_phpwrap
 
 import (
+	"errors"
+
 	"github.com/RangelReale/fdep"
+	"github.com/RangelReale/fproto-wrap"
 )
 
 // Root wrapper struct
@@ -12,6 +15,9 @@ type Wrapper struct {
 	TypeConverters []TypeConverterPlugin
 	ServiceGen     ServiceGen
 	Customizers    []Customizer
+
+	// Order in which the owned files are generated, and listed on Generator.OwnedFiles
+	FileOrder fproto_wrap.FileOrder
 }
 
 // Creates a new wrapper
@@ -23,14 +29,23 @@ func NewWrapper(dep *fdep.Dep) *Wrapper {
 
 // Generates one file
 func (wp *Wrapper) GenerateFile(filename string, output FileOutput) error {
-	g, err := NewGenerator(wp.dep, filename)
-	g.NSSource = wp.NSSource
-	g.TypeConverters = wp.TypeConverters
-	g.ServiceGen = wp.ServiceGen
-	g.Customizers = wp.Customizers
+	df, ok := wp.dep.Files[filename]
+	if !ok {
+		return errors.New("File not found")
+	}
+
+	files, err := fproto_wrap.OwnedFiles(wp.dep, wp.FileOrder)
+	if err != nil {
+		return err
+	}
+
+	g, err := wp.newFileGenerator(df, files)
 	if err != nil {
 		return err
 	}
+	if g == nil {
+		return nil
+	}
 
 	err = g.Generate()
 	if err != nil {
@@ -38,12 +53,10 @@ func (wp *Wrapper) GenerateFile(filename string, output FileOutput) error {
 	}
 
 	// write all files
-	for _, gf := range g.Files {
-		if gf != nil && gf.HaveData() {
-			err = output.Output(gf)
-			if err != nil {
-				return err
-			}
+	for _, gf := range g.DataFiles() {
+		err = output.Output(gf)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -51,46 +64,72 @@ func (wp *Wrapper) GenerateFile(filename string, output FileOutput) error {
 }
 
 // Generates all owned files.
+// The output is only finalized if the generation succeeds.
 func (wp *Wrapper) Generate(output FileOutput) error {
-	output.Initialize()
-	defer output.Finalize()
+	err := output.Initialize()
+	if err != nil {
+		return err
+	}
 
-	for _, df := range wp.dep.Files {
-		if df.DepType == fdep.DepType_Own {
-			g, err := NewGenerator(wp.dep, df.FilePath)
-			if err != nil {
-				return err
-			}
+	err = wp.generate(output)
+	if err != nil {
+		return err
+	}
 
-			if !g.IsFileWrap(df) {
-				continue
-			}
+	return output.Finalize()
+}
 
-			g.NSSource = wp.NSSource
-			g.TypeConverters = wp.TypeConverters
-			g.ServiceGen = wp.ServiceGen
-			g.Customizers = wp.Customizers
+func (wp *Wrapper) generate(output FileOutput) error {
+	files, err := fproto_wrap.OwnedFiles(wp.dep, wp.FileOrder)
+	if err != nil {
+		return err
+	}
 
-			err = g.Generate()
+	for _, df := range files {
+		g, err := wp.newFileGenerator(df, files)
+		if err != nil {
+			return err
+		}
+		if g == nil {
+			continue
+		}
+
+		err = g.Generate()
+		if err != nil {
+			return err
+		}
+
+		// write all files
+		for _, gf := range g.DataFiles() {
+			err = output.Output(gf)
 			if err != nil {
 				return err
 			}
-
-			// write all files
-			for _, gf := range g.Files {
-				if gf != nil && gf.HaveData() {
-					err = output.Output(gf)
-					if err != nil {
-						return err
-					}
-				}
-			}
-
 		}
 	}
 	return nil
 }
 
+// Creates the generator of an owned file, or nil if the file is not wrapped.
+func (wp *Wrapper) newFileGenerator(df *fdep.DepFile, ownedFiles []*fdep.DepFile) (*Generator, error) {
+	g, err := NewGenerator(wp.dep, df.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !g.IsFileWrap(df) {
+		return nil, nil
+	}
+
+	g.OwnedFiles = ownedFiles
+	g.NSSource = wp.NSSource
+	g.TypeConverters = wp.TypeConverters
+	g.ServiceGen = wp.ServiceGen
+	g.Customizers = wp.Customizers
+
+	return g, nil
+}
+
 // Generates all owned files.
 func (wp *Wrapper) GenerateFiles(outputpath string) error {
 	output := NewFileOutput_Default(outputpath)
@@ -0,0 +1,32 @@
+package fproto_wrap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parses a plugin specification in the format "name" or "name:key=value,key=value", as used on command line flags.
+func ParsePluginSpec(spec string) (name string, options map[string]string, err error) {
+	options = make(map[string]string)
+
+	name = spec
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name = spec[:i]
+		for _, opt := range strings.Split(spec[i+1:], ",") {
+			if opt == "" {
+				continue
+			}
+			kv := strings.SplitN(opt, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return "", nil, fmt.Errorf("Invalid option '%s' on plugin '%s'", opt, spec)
+			}
+			options[kv[0]] = kv[1]
+		}
+	}
+
+	if name == "" {
+		return "", nil, fmt.Errorf("Plugin name is required: '%s'", spec)
+	}
+
+	return name, options, nil
+}
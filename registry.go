@@ -0,0 +1,65 @@
+package fproto_wrap
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Named factories of one kind of plugin, usually registered on the plugin package init function.
+// The wrappers keep one registry per plugin kind, and type the factories themselves.
+type Registry struct {
+	kind      string
+	lock      sync.RWMutex
+	factories map[string]interface{}
+}
+
+// Creates a registry. The kind names the plugins on panic and error messages, like "type converter plugin".
+func NewRegistry(kind string) *Registry {
+	return &Registry{
+		kind:      kind,
+		factories: make(map[string]interface{}),
+	}
+}
+
+// Registers a factory by name. Panics if the name is already registered.
+func (r *Registry) Register(name string, factory interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.factories[name]; ok {
+		panic(fmt.Sprintf("Duplicated %s: %s", r.kind, name))
+	}
+	r.factories[name] = factory
+}
+
+// Returns the factory registered with the name
+func (r *Registry) Lookup(name string) (interface{}, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	factory, ok := r.factories[name]
+	return factory, ok
+}
+
+// Returns the factory registered with the name, or an error if it is unknown
+func (r *Registry) Get(name string) (interface{}, error) {
+	factory, ok := r.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("Unknown %s: %s", r.kind, name)
+	}
+	return factory, nil
+}
+
+// Lists the registered names, in ascending order
+func (r *Registry) List() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var ret []string
+	for name := range r.factories {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}